@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/jtb75/wiz-scan/pkg/logging"
+	"github.com/jtb75/wiz-scan/pkg/utilities"
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// Version and Commit are set by the Makefile with -ldflags.
+var (
+	Version = "dev"
+	Commit  = "unknown"
+)
+
+// command is a wiz-scan subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(arguments []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"scan", "Scan this host and summarise the results locally, without contacting the Wiz API", runScan},
+		{"compare", "Scan this host and report which vulnerabilities would be added, kept or ignored", runCompare},
+		{"fetch-known", "Save the vulnerabilities Wiz already knows on this asset for an offline compare", runFetchKnown},
+		{"publish", "Scan this host, compare with Wiz and publish the new findings (the default without a command)", runPublish},
+		{"daemon", "Keep running and publish on a cron schedule, with retries and a status endpoint", runDaemon},
+		{"install", "Install wiz-scan and schedule a daily run", runInstall},
+		{"uninstall", "Remove wiz-scan and its scheduled run", runUninstall},
+		{"config", "Show or change the saved configuration (config get [key], config set key=value...)", runConfig},
+		{"history", "Report new, fixed and persisting findings between recorded runs", runHistory},
+		{"doctor", "Check the configuration, connectivity and scanner of this host", runDoctor},
+		{"version", "Print the version of wiz-scan", runVersion},
+		{"help", "Show the available commands", runHelp},
+	}
+}
+
+// findCommand returns the subcommand called name, or nil when name isn't one.
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// newFlagSet creates the flag set of a subcommand with a usage line.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: wiz-scan %s %s\n\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// loadArguments parses the flags of a subcommand and applies its log level. When the command
+// shouldn't run, such as after -h, it returns nil and the exit code.
+func loadArguments(fs *flag.FlagSet, arguments []string, groups ...utilities.FlagGroup) (*utilities.Arguments, int) {
+	args, err := utilities.LoadArguments(fs, arguments, groups...)
+	if err == flag.ErrHelp {
+		return nil, 0
+	}
+	if err != nil {
+		log.Errorf("Failed to parse arguments: %v", err)
+		return nil, 2
+	}
+	LogInit(args)
+	configureProxy(args)
+	if err := utilities.ResolveSecrets(args); err != nil {
+		log.Errorf("%v", err)
+		return nil, 1
+	}
+	return args, 0
+}
+
+func runScan(arguments []string) int {
+	fs := newFlagSet("scan", "[flags]")
+	output := fs.String("output", "", "Save the scan results to this JSON file, or - for stdout, for compare -scan-results")
+	args, code := loadArguments(fs, arguments, utilities.OptionalConnectionFlags, utilities.ScanFlags)
+	if args == nil {
+		return code
+	}
+	args.Output = *output
+	return run(args, stageScan)
+}
+
+// runCompare compares with Wiz, or fully offline with -known-vulns, using saved scan results
+// when -scan-results is given and scanning this host otherwise.
+func runCompare(arguments []string) int {
+	fs := newFlagSet("compare", "[flags]")
+	scanResults := fs.String("scan-results", "", "Compare the scan results saved by scan -output instead of scanning this host")
+	knownVulns := fs.String("known-vulns", "", "Compare with the vulnerabilities saved by fetch-known -output instead of fetching them from Wiz")
+	providerID := fs.String("provider-id", "", "Provider ID of the scanned asset, the same as -scanProviderId")
+	output := fs.String("output", "", "Write the payload that would be published to this JSON file, or - for stdout")
+	args, code := loadArguments(fs, arguments, utilities.OptionalConnectionFlags, utilities.ScanFlags, utilities.CompareFlags)
+	if args == nil {
+		return code
+	}
+	args.ScanResults, args.KnownVulns, args.Output = *scanResults, *knownVulns, *output
+	if *providerID != "" {
+		args.ScanProviderID = *providerID
+	}
+
+	// Offline only the asset is needed, to tell its findings apart
+	var err error
+	if args.KnownVulns == "" {
+		err = utilities.ValidateArguments(args)
+	} else if args.ScanProviderID == "" {
+		err = errors.New("-provider-id is required to compare offline")
+	}
+	if err != nil {
+		log.Errorf("Failed to parse arguments: %v", err)
+		return 2
+	}
+	return run(args, stageCompare)
+}
+
+// runFetchKnown implements `wiz-scan fetch-known`, saving the vulnerabilities Wiz knows on
+// the asset for `compare -known-vulns`.
+func runFetchKnown(arguments []string) int {
+	fs := newFlagSet("fetch-known", "[flags]")
+	output := fs.String("output", "-", "Save the known vulnerabilities to this JSON file, or - for stdout")
+	args, code := loadArguments(fs, arguments, utilities.ConnectionFlags)
+	if args == nil {
+		return code
+	}
+
+	logging.SetPhase("fetch")
+	_, response, err := fetchKnownVulns(args)
+	if err != nil {
+		log.Errorf("Error gathering known vulnerabilities: %v", err)
+		return 1
+	}
+	if err := writeJSONOutput(*output, response); err != nil {
+		log.Errorf("Error writing known vulnerabilities: %v", err)
+		return 1
+	}
+	log.Infof("Saved %d known vulnerabilities of %s", len(response), args.ScanProviderID)
+	return 0
+}
+
+func runPublish(arguments []string) int {
+	fs := newFlagSet("publish", "[flags]")
+	args, code := loadArguments(fs, arguments, utilities.ConnectionFlags, utilities.ScanFlags, utilities.CompareFlags, utilities.PublishFlags)
+	if args == nil {
+		return code
+	}
+	return run(args, stagePublish)
+}
+
+func runInstall(arguments []string) int {
+	fs := newFlagSet("install", "[flags]")
+	args, code := loadArguments(fs, arguments, utilities.ConnectionFlags, utilities.ScanFlags, utilities.CompareFlags, utilities.PublishFlags)
+	if args == nil {
+		return code
+	}
+	return install(args)
+}
+
+func runUninstall(arguments []string) int {
+	fs := newFlagSet("uninstall", "")
+	if err := fs.Parse(arguments); err != nil {
+		return 2
+	}
+	return uninstall()
+}
+
+func install(args *utilities.Arguments) int {
+	log.Info("Initiating Install")
+	if err := utilities.InstallAndScheduleTask(args); err != nil {
+		log.Errorf("Installation failed: %v", err)
+		return 1
+	}
+	log.Info("Installation and task scheduling completed successfully")
+	return 0
+}
+
+func uninstall() int {
+	log.Info("Initiating Uninstall")
+	if err := utilities.UninstallAndRemoveTask(); err != nil {
+		log.Errorf("Uninstallation failed: %v", err)
+		return 1
+	}
+	log.Info("Uninstallation and task removal completed successfully")
+	return 0
+}
+
+func runVersion(arguments []string) int {
+	fs := newFlagSet("version", "")
+	if err := fs.Parse(arguments); err != nil {
+		return 2
+	}
+	fmt.Printf("wiz-scan %s (commit %s)\n", Version, Commit)
+	return 0
+}
+
+func runHelp(arguments []string) int {
+	fmt.Println("Usage: wiz-scan <command> [flags]")
+	fmt.Println("       wiz-scan [flags]    scan, compare and publish as before commands existed")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Println()
+	fmt.Println("Run `wiz-scan <command> -h` for the flags of a command.")
+	return 0
+}
+
+// printScanSummary prints what a local scan found to w.
+func printScanSummary(w io.Writer, results *wizcli.AggregatedScanResults) {
+	vulnerabilities := 0
+	for _, pkg := range results.OsPackages {
+		vulnerabilities += len(pkg.Vulnerabilities)
+	}
+	for _, lib := range results.Libraries {
+		vulnerabilities += len(lib.Vulnerabilities)
+	}
+	for _, app := range results.Applications {
+		vulnerabilities += len(app.Vulnerabilities)
+	}
+	for _, cpe := range results.Cpes {
+		vulnerabilities += len(cpe.Vulnerabilities)
+	}
+	fmt.Fprintf(w, "OS packages:     %d\n", len(results.OsPackages))
+	fmt.Fprintf(w, "Libraries:       %d\n", len(results.Libraries))
+	fmt.Fprintf(w, "Applications:    %d\n", len(results.Applications))
+	fmt.Fprintf(w, "CPEs:            %d\n", len(results.Cpes))
+	fmt.Fprintf(w, "Vulnerabilities: %d\n", vulnerabilities)
+}
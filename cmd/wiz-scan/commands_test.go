@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// captureStdout returns what run writes to stdout together with its exit code.
+func captureStdout(t *testing.T, run func() int) ([]byte, int) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	read := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		read <- data
+	}()
+	code := run()
+	w.Close()
+	return <-read, code
+}
+
+func TestOutputToStdoutIsOnlyJSON(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configFile, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WIZ_CONFIG", configFile)
+	t.Setenv("HOME", dir)
+
+	trivyReport, err := filepath.Abs("../../pkg/scanner/testdata/trivy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, code := captureStdout(t, func() int {
+		return runScan([]string{"-scanner", "trivy", "-scannerReport", trivyReport, "-output", "-"})
+	})
+	if code != 0 {
+		t.Fatalf("scan exited with %d", code)
+	}
+	var results wizcli.AggregatedScanResults
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatalf("scan -output - wrote more than the results to stdout: %v\n%s", err, out)
+	}
+	if len(results.OsPackages) == 0 || len(results.Libraries) == 0 {
+		t.Errorf("scan results are %+v", results)
+	}
+
+	scanResults := filepath.Join(dir, "scan.json")
+	knownVulns := filepath.Join(dir, "known.json")
+	if err := os.WriteFile(scanResults, out, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(knownVulns, []byte("[]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	out, code = captureStdout(t, func() int {
+		return runCompare([]string{"-scan-results", scanResults, "-known-vulns", knownVulns, "-provider-id", "i-0123456789", "-report", "table", "-output", "-"})
+	})
+	if code != 0 {
+		t.Fatalf("compare exited with %d", code)
+	}
+	var payload vulnerability.IntegrationData
+	if err := json.Unmarshal(out, &payload); err != nil {
+		t.Fatalf("compare -output - wrote more than the payload to stdout: %v\n%s", err, out)
+	}
+	if len(payload.DataSources) != 1 || len(payload.DataSources[0].Assets) != 1 || len(payload.DataSources[0].Assets[0].VulnerabilityFindings) == 0 {
+		t.Errorf("payload is %+v", payload)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtb75/wiz-scan/pkg/secrets"
+	"github.com/jtb75/wiz-scan/pkg/utilities"
+)
+
+// runConfig implements `wiz-scan config get [key]`, `wiz-scan config set key=value...` and
+// `wiz-scan config show [-effective]`.
+func runConfig(arguments []string) int {
+	fs := newFlagSet("config", "get [key] | set key=value... | show [-effective] [flags]")
+	configFilePath := fs.String("config", utilities.DefaultConfigPath(), "Path to the configuration file")
+	reveal := fs.Bool("reveal", false, "Print secrets instead of masking them")
+	if err := fs.Parse(arguments); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var err error
+	switch fs.Arg(0) {
+	case "get":
+		err = configGet(*configFilePath, fs.Args()[1:], *reveal)
+	case "set":
+		err = configSet(*configFilePath, fs.Args()[1:])
+	case "show":
+		return configShow(*configFilePath, fs.Args()[1:], *reveal)
+	default:
+		err = fmt.Errorf("unknown config command %q, expected get, set or show", fs.Arg(0))
+	}
+	if err != nil {
+		log.Errorf("%v", err)
+		return 1
+	}
+	return 0
+}
+
+func configGet(path string, keys []string, reveal bool) error {
+	config, err := utilities.ReadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	values, err := utilities.ConfigValues(config)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		keys = utilities.SortedKeys(values)
+	}
+	for _, key := range keys {
+		value, ok := values[key]
+		if !ok {
+			return unknownConfigKey(key, values)
+		}
+		text := fmt.Sprint(value)
+		if utilities.SecretKeys[key] && !reveal && !secrets.IsReference(text) {
+			text = maskSecret(text)
+		}
+		if len(keys) == 1 {
+			fmt.Println(text)
+		} else {
+			fmt.Printf("%s = %s\n", key, text)
+		}
+	}
+	return nil
+}
+
+func configSet(path string, assignments []string) error {
+	if len(assignments) == 0 {
+		return errors.New("config set needs at least one key=value")
+	}
+
+	config := utilities.DefaultArguments()
+	if _, err := os.Stat(path); err == nil {
+		if config, err = utilities.ReadConfigFile(path); err != nil {
+			return err
+		}
+	}
+	values, err := utilities.ConfigValues(config)
+	if err != nil {
+		return err
+	}
+
+	for _, assignment := range assignments {
+		key, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return fmt.Errorf("invalid assignment %q, expected key=value", assignment)
+		}
+		current, ok := values[key]
+		if !ok {
+			return unknownConfigKey(key, values)
+		}
+		switch current.(type) {
+		case bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false", key)
+			}
+			values[key] = b
+		case json.Number:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be a whole number", key)
+			}
+			values[key] = n
+		default:
+			values[key] = value
+		}
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	updated := &utilities.Arguments{}
+	if err := json.Unmarshal(data, updated); err != nil {
+		return fmt.Errorf("failed to update config: %w", err)
+	}
+	if err := utilities.SaveConfigFile(updated, path); err != nil {
+		return err
+	}
+	fmt.Printf("Saved %s\n", path)
+	return nil
+}
+
+// configShow implements `wiz-scan config show`. With -effective it prints the settings a
+// command would run with, merged from its flags, the environment, the configuration file and
+// the defaults, together with where each came from.
+func configShow(configFilePath string, arguments []string, reveal bool) int {
+	fs := newFlagSet("config show", "[-effective] [flags of any command]")
+	effective := fs.Bool("effective", false, "Merge the flags, environment, configuration file and defaults")
+	fs.BoolVar(&reveal, "reveal", reveal, "Print secrets instead of masking them")
+
+	if !hasFlag(arguments, "config") {
+		arguments = append([]string{"-config", configFilePath}, arguments...)
+	}
+	args, sources, err := utilities.LoadConfig(fs, arguments, utilities.OptionalConnectionFlags,
+		utilities.ScanFlags, utilities.CompareFlags, utilities.PublishFlags, utilities.DaemonFlags)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		log.Errorf("%v", err)
+		return 1
+	}
+	if !*effective {
+		if err := configGet(fs.Lookup("config").Value.String(), nil, reveal); err != nil {
+			log.Errorf("%v", err)
+			return 1
+		}
+		return 0
+	}
+
+	values, err := utilities.ConfigValues(args)
+	if err != nil {
+		log.Errorf("%v", err)
+		return 1
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, key := range utilities.SortedKeys(values) {
+		text := fmt.Sprint(values[key])
+		if utilities.SecretKeys[key] && !reveal && !secrets.IsReference(text) {
+			text = maskSecret(text)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", key, dash(text), sources[key])
+	}
+	tw.Flush()
+	return 0
+}
+
+// hasFlag reports whether arguments set the flag name.
+func hasFlag(arguments []string, name string) bool {
+	for _, arg := range arguments {
+		arg = strings.TrimLeft(arg, "-")
+		if arg == name || strings.HasPrefix(arg, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// dash stands in for an empty value so columns stay aligned.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func unknownConfigKey(key string, values map[string]interface{}) error {
+	keys := utilities.SortedKeys(values)
+	return fmt.Errorf("unknown config key %q (known keys: %s)", key, strings.Join(keys, ", "))
+}
+
+// maskSecret keeps the last four characters of a secret so it can be recognised.
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
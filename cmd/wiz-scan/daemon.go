@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jtb75/wiz-scan/pkg/logging"
+	"github.com/jtb75/wiz-scan/pkg/schedule"
+	"github.com/jtb75/wiz-scan/pkg/utilities"
+)
+
+// runResult describes one scheduled run, including its retries.
+type runResult struct {
+	RunID    string     `json:"runId"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Attempts int        `json:"attempts"`
+	ExitCode int        `json:"exitCode"`
+	Result   string     `json:"result"`
+	// PolicyGateFailed is set when the exit code is the policy gate's on a completed run
+	PolicyGateFailed bool `json:"policyGateFailed,omitempty"`
+}
+
+// daemonStatus is what /status reports.
+type daemonStatus struct {
+	Schedule    string     `json:"schedule"`
+	NextRun     time.Time  `json:"nextRun"`
+	Current     *runResult `json:"current,omitempty"`
+	LastRun     *runResult `json:"lastRun,omitempty"`
+	SkippedRuns int        `json:"skippedRuns"`
+}
+
+// daemon runs publish on a schedule, one run at a time.
+type daemon struct {
+	args     *utilities.Arguments
+	schedule *schedule.Schedule
+	jitter   time.Duration
+	backoff  time.Duration
+	// runner is runStage, replaced in tests
+	runner func(*utilities.Arguments, stage) (int, bool)
+
+	mu     sync.Mutex
+	status daemonStatus
+	done   sync.WaitGroup
+}
+
+// runDaemon implements `wiz-scan daemon`, which keeps running and scans, compares and
+// publishes on its own schedule instead of relying on cron or the Task Scheduler.
+func runDaemon(arguments []string) int {
+	fs := newFlagSet("daemon", "[flags]")
+	args, code := loadArguments(fs, arguments, utilities.ConnectionFlags, utilities.ScanFlags,
+		utilities.CompareFlags, utilities.PublishFlags, utilities.DaemonFlags)
+	if args == nil {
+		return code
+	}
+	d, err := newDaemon(args)
+	if err != nil {
+		log.Errorf("Failed to parse arguments: %v", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if args.Listen != "" {
+		server := &http.Server{Addr: args.Listen, Handler: d.handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorf("Status endpoint stopped: %v", err)
+			}
+		}()
+		defer server.Close()
+		log.Infof("Serving /healthz and /status on http://%s", args.Listen)
+	}
+
+	d.loop(ctx)
+	log.Info("Stopping, waiting for the current run to finish")
+	d.done.Wait()
+	return 0
+}
+
+func newDaemon(args *utilities.Arguments) (*daemon, error) {
+	sched, err := schedule.Parse(args.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	jitter, err := time.ParseDuration(args.Jitter)
+	if err != nil || jitter < 0 {
+		return nil, fmt.Errorf("invalid jitter %q", args.Jitter)
+	}
+	backoff, err := time.ParseDuration(args.RetryBackoff)
+	if err != nil || backoff <= 0 {
+		return nil, fmt.Errorf("invalid retryBackoff %q", args.RetryBackoff)
+	}
+	if args.Retries < 0 {
+		return nil, fmt.Errorf("invalid retries %d", args.Retries)
+	}
+	return &daemon{
+		args:     args,
+		schedule: sched,
+		jitter:   jitter,
+		backoff:  backoff,
+		runner:   runStage,
+		status:   daemonStatus{Schedule: sched.String()},
+	}, nil
+}
+
+// loop starts a run at every scheduled time until ctx is cancelled. A run still going at
+// the next scheduled time makes that one skipped rather than overlap with it.
+func (d *daemon) loop(ctx context.Context) {
+	for {
+		next := d.nextRun(time.Now())
+		if next.IsZero() {
+			log.Errorf("The schedule %q never runs", d.schedule)
+			return
+		}
+		d.mu.Lock()
+		d.status.NextRun = next
+		d.mu.Unlock()
+		log.Infof("Next run at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		result, started := d.start()
+		if !started {
+			log.Warnf("Skipping the run scheduled at %s, run %s is still going", next.Format(time.RFC3339), result.RunID)
+			continue
+		}
+		d.done.Add(1)
+		go func() {
+			defer d.done.Done()
+			d.execute(ctx, result)
+		}()
+	}
+}
+
+// nextRun returns the scheduled time after now with a random delay of up to the jitter.
+func (d *daemon) nextRun(now time.Time) time.Time {
+	next := d.schedule.Next(now)
+	if !next.IsZero() && d.jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(d.jitter))))
+	}
+	return next
+}
+
+// start records a new current run, unless one is still going; then it counts a skipped
+// run and returns the current one.
+func (d *daemon) start() (*runResult, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.status.Current != nil {
+		d.status.SkippedRuns++
+		return d.status.Current, false
+	}
+	d.status.Current = &runResult{RunID: logging.StartRun(), Started: time.Now(), Result: "running"}
+	return d.status.Current, true
+}
+
+// execute runs publish, retrying a failed run with exponential backoff. Secret references
+// are resolved again for every attempt so rotated credentials are picked up. A run that
+// only failed the policy gate completed, so it isn't retried.
+func (d *daemon) execute(ctx context.Context, result *runResult) {
+	wait := d.backoff
+	for {
+		d.mu.Lock()
+		result.Attempts++
+		d.mu.Unlock()
+		log.Infof("Starting scheduled run (attempt %d of %d)", result.Attempts, d.args.Retries+1)
+
+		code, gated := 1, false
+		if err := utilities.RefreshSecrets(d.args); err != nil {
+			log.Errorf("%v", err)
+		} else {
+			code, gated = d.runner(d.args, stagePublish)
+		}
+		logging.SetPhase("")
+		d.mu.Lock()
+		result.ExitCode = code
+		result.PolicyGateFailed = gated
+		d.mu.Unlock()
+		if code == 0 || gated || result.Attempts > d.args.Retries {
+			break
+		}
+
+		log.Warnf("Run failed with exit code %d, retrying in %s", code, wait)
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+			wait *= 2
+			continue
+		}
+		break
+	}
+
+	finished := time.Now()
+	d.mu.Lock()
+	result.Finished = &finished
+	switch {
+	case result.ExitCode == 0:
+		result.Result = "succeeded"
+	case result.PolicyGateFailed:
+		result.Result = "policy gate failed"
+	default:
+		result.Result = "failed"
+	}
+	d.status.LastRun = result
+	d.status.Current = nil
+	d.mu.Unlock()
+	log.Infof("Scheduled run %s (attempts: %d)", result.Result, result.Attempts)
+}
+
+// handler serves /healthz, which fails while the last run failed for another reason than
+// the policy gate, and /status.
+func (d *daemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		last := d.status.LastRun
+		failed := last != nil && last.ExitCode != 0 && !last.PolicyGateFailed
+		d.mu.Unlock()
+		if failed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "last run %s failed with exit code %d\n", last.RunID, last.ExitCode)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		data, err := json.MarshalIndent(d.status, "", "  ")
+		d.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(data, '\n'))
+	})
+	return mux
+}
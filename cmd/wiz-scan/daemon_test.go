@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jtb75/wiz-scan/pkg/utilities"
+)
+
+func testDaemon(t *testing.T, jitter string, retries int) *daemon {
+	t.Helper()
+	d, err := newDaemon(&utilities.Arguments{Schedule: "0 2 * * *", Jitter: jitter, Retries: retries, RetryBackoff: "1ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// outcome is what a run returns: its exit code and whether only the policy gate failed.
+type outcome struct {
+	code  int
+	gated bool
+}
+
+// scriptedRunner returns the outcomes in order, repeating the last one.
+func scriptedRunner(calls *int, outcomes ...outcome) func(*utilities.Arguments, stage) (int, bool) {
+	return func(*utilities.Arguments, stage) (int, bool) {
+		o := outcomes[len(outcomes)-1]
+		if *calls < len(outcomes) {
+			o = outcomes[*calls]
+		}
+		*calls++
+		return o.code, o.gated
+	}
+}
+
+func healthz(d *daemon) int {
+	recorder := httptest.NewRecorder()
+	d.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	return recorder.Code
+}
+
+func TestDaemonRetriesOperationalFailures(t *testing.T) {
+	d := testDaemon(t, "0s", 3)
+	calls := 0
+	d.runner = scriptedRunner(&calls, outcome{1, false}, outcome{1, false}, outcome{0, false})
+
+	result, _ := d.start()
+	d.execute(context.Background(), result)
+	if calls != 3 || result.Attempts != 3 || result.Result != "succeeded" {
+		t.Errorf("%d calls, %d attempts, %s", calls, result.Attempts, result.Result)
+	}
+	if code := healthz(d); code != http.StatusOK {
+		t.Errorf("healthz is %d after a successful retry", code)
+	}
+
+	calls = 0
+	d.runner = scriptedRunner(&calls, outcome{1, false})
+	result, _ = d.start()
+	d.execute(context.Background(), result)
+	if calls != 4 || result.Result != "failed" {
+		t.Errorf("%d calls, %s, want 4 and failed", calls, result.Result)
+	}
+	if code := healthz(d); code != http.StatusServiceUnavailable {
+		t.Errorf("healthz is %d after a failed run", code)
+	}
+}
+
+func TestDaemonDoesntRetryThePolicyGate(t *testing.T) {
+	d := testDaemon(t, "0s", 3)
+	calls := 0
+	// The gate may use exit code 1 too, only the flag tells it apart
+	d.runner = scriptedRunner(&calls, outcome{1, true})
+
+	result, _ := d.start()
+	d.execute(context.Background(), result)
+	if calls != 1 || result.ExitCode != 1 || !result.PolicyGateFailed || result.Result != "policy gate failed" {
+		t.Errorf("%d calls, %+v", calls, result)
+	}
+	if code := healthz(d); code != http.StatusOK {
+		t.Errorf("healthz is %d after a completed run that failed the gate", code)
+	}
+}
+
+func TestDaemonResolvesSecretsForEveryAttempt(t *testing.T) {
+	t.Setenv("WIZ_SCAN_TEST_SECRET", "first")
+	d := testDaemon(t, "0s", 1)
+	d.args.WizClientSecret = "env:WIZ_SCAN_TEST_SECRET"
+	if err := utilities.ResolveSecrets(d.args); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	d.runner = func(args *utilities.Arguments, _ stage) (int, bool) {
+		seen = append(seen, args.WizClientSecret)
+		os.Setenv("WIZ_SCAN_TEST_SECRET", "rotated")
+		return len(seen) % 2, false
+	}
+	result, _ := d.start()
+	d.execute(context.Background(), result)
+	if len(seen) != 2 || seen[0] != "first" || seen[1] != "rotated" {
+		t.Errorf("runs saw secrets %q", seen)
+	}
+}
+
+func TestDaemonSkipsWhileRunning(t *testing.T) {
+	d := testDaemon(t, "0s", 0)
+	first, started := d.start()
+	if !started {
+		t.Fatal("the first run didn't start")
+	}
+	current, started := d.start()
+	if started || current != first || d.status.SkippedRuns != 1 {
+		t.Errorf("a second run started while the first was going (skipped %d)", d.status.SkippedRuns)
+	}
+
+	calls := 0
+	d.runner = scriptedRunner(&calls, outcome{0, false})
+	d.execute(context.Background(), first)
+	if _, started := d.start(); !started {
+		t.Error("no run started after the previous one finished")
+	}
+}
+
+func TestDaemonJitter(t *testing.T) {
+	now := time.Date(2026, 1, 14, 10, 30, 0, 0, time.Local)
+	scheduled := time.Date(2026, 1, 15, 2, 0, 0, 0, time.Local)
+
+	if got := testDaemon(t, "0s", 0).nextRun(now); !got.Equal(scheduled) {
+		t.Errorf("without jitter the run is at %s, want %s", got, scheduled)
+	}
+
+	d := testDaemon(t, "1h", 0)
+	delayed := false
+	for i := 0; i < 100; i++ {
+		got := d.nextRun(now)
+		if got.Before(scheduled) || !got.Before(scheduled.Add(time.Hour)) {
+			t.Fatalf("run at %s, outside the hour after %s", got, scheduled)
+		}
+		delayed = delayed || got.After(scheduled)
+	}
+	if !delayed {
+		t.Error("no run was delayed")
+	}
+
+	if _, err := newDaemon(&utilities.Arguments{Schedule: "@daily", Jitter: "-1m", RetryBackoff: "1m"}); err == nil {
+		t.Error("a negative jitter was accepted")
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jtb75/wiz-scan/pkg/scanner"
+	"github.com/jtb75/wiz-scan/pkg/secrets"
+	"github.com/jtb75/wiz-scan/pkg/utilities"
+	"github.com/jtb75/wiz-scan/pkg/wizapi"
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// checkStatus is the outcome of a doctor check.
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+	checkSkip checkStatus = "SKIP"
+)
+
+// check is the outcome of one doctor check, with a hint on how to fix a failure.
+type check struct {
+	name   string
+	status checkStatus
+	detail string
+	hint   string
+}
+
+// wizcliVersionTimeout bounds how long the downloaded wizcli may take to print its version.
+const wizcliVersionTimeout = 30 * time.Second
+
+// minTempSpace is what downloading wizcli and holding scan results needs in the temp directory.
+const minTempSpace = 1 << 30
+
+// requiredScopes are the service account permissions a publishing run is expected to use.
+// The names haven't been confirmed against every tenant, so a missing one is a warning and
+// the asset lookup after it is what proves access.
+var requiredScopes = []string{
+	"read:resources",
+	"read:vulnerabilities",
+	"create:external_data_ingestion",
+	"read:system_activities",
+}
+
+// doctor runs the checks in order, each seeing the outcome of the ones before it.
+type doctor struct {
+	args    *utilities.Arguments
+	sources utilities.Sources
+	failed  map[string]bool
+	api     *wizapi.WizAPI
+}
+
+// runDoctor implements `wiz-scan doctor`, checking step by step what a run needs so a
+// failing scheduled run can be diagnosed on the host.
+func runDoctor(arguments []string) int {
+	fs := newFlagSet("doctor", "[flags]")
+	args, sources, err := utilities.LoadConfig(fs, arguments, utilities.OptionalConnectionFlags, utilities.ScanFlags)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	var fileErr *utilities.ConfigFileError
+	if err != nil && !errors.As(err, &fileErr) {
+		log.Errorf("Failed to parse arguments: %v", err)
+		return 2
+	}
+	LogInit(args)
+	configureProxy(args)
+
+	d := &doctor{args: args, sources: sources, failed: make(map[string]bool)}
+	steps := []func() check{
+		func() check { return d.configFile(fileErr) },
+		d.secrets,
+		d.settings,
+		d.privileges,
+		d.stateDir,
+		d.tempSpace,
+		d.directories,
+		d.scanner,
+		func() check { return d.endpoint("Auth endpoint", args.WizAuthURL) },
+		func() check { return d.endpoint("Query endpoint", args.WizQueryURL) },
+		d.wizcliEndpoint,
+		d.authentication,
+		d.permissions,
+		d.asset,
+		d.wizcli,
+		d.scheduledTask,
+	}
+
+	failed := 0
+	for _, step := range steps {
+		c := step()
+		if c.status == checkFail {
+			failed++
+			d.failed[c.name] = true
+		}
+		fmt.Printf("[%s] %s: %s\n", c.status, c.name, c.detail)
+		if c.hint != "" && c.status != checkPass {
+			fmt.Printf("       hint: %s\n", c.hint)
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d of %d checks failed\n", failed, len(steps))
+		return 1
+	}
+	return 0
+}
+
+func pass(name, detail string) check {
+	return check{name: name, status: checkPass, detail: detail}
+}
+
+func fail(name string, err error, hint string) check {
+	return check{name: name, status: checkFail, detail: err.Error(), hint: hint}
+}
+
+func skip(name, reason string) check {
+	return check{name: name, status: checkSkip, detail: reason}
+}
+
+// skipAfter skips name when one of the checks it depends on failed.
+func (d *doctor) skipAfter(name string, dependencies ...string) (check, bool) {
+	for _, dependency := range dependencies {
+		if d.failed[dependency] {
+			return skip(name, dependency+" failed"), true
+		}
+	}
+	return check{}, false
+}
+
+func (d *doctor) configFile(fileErr *utilities.ConfigFileError) check {
+	const name = "Configuration file"
+	if fileErr != nil {
+		hint := "Check that the file exists and is readable by this user, or run `wiz-scan install` again."
+		if strings.Contains(fileErr.Error(), "another host") || strings.Contains(fileErr.Error(), "key file") {
+			hint = "Encrypted configurations only open on the host and for the user that saved them; save it again here."
+		} else if strings.Contains(fileErr.Error(), utilities.PassphraseEnv) || strings.Contains(fileErr.Error(), "passphrase") {
+			hint = "Set " + utilities.PassphraseEnv + " to the passphrase the configuration was saved with."
+		}
+		return fail(name, fileErr, hint)
+	}
+	for _, source := range d.sources {
+		if path, ok := strings.CutPrefix(source, "file "); ok {
+			return pass(name, "read "+path)
+		}
+	}
+	return pass(name, "none, using flags and environment")
+}
+
+func (d *doctor) secrets() check {
+	const name = "Secret references"
+	refs := []string{}
+	for key, value := range map[string]string{"wizClientId": d.args.WizClientID, "wizClientSecret": d.args.WizClientSecret} {
+		if secrets.IsReference(value) {
+			refs = append(refs, key)
+		}
+	}
+	if len(refs) == 0 {
+		return pass(name, "none")
+	}
+	if err := utilities.ResolveSecrets(d.args); err != nil {
+		return fail(name, err, "Check that the referenced file, variable, command or Vault secret is available to this user.")
+	}
+	return pass(name, "resolved "+strings.Join(refs, ", "))
+}
+
+func (d *doctor) settings() check {
+	const name = "Settings"
+	if err := utilities.ValidateArguments(d.args); err != nil {
+		return fail(name, err, "Set it with a flag, a WIZ_ environment variable or `wiz-scan config set`; `wiz-scan config show -effective` shows where each setting comes from.")
+	}
+	return pass(name, fmt.Sprintf("%s asset %s in %s", d.args.ScanCloudType, d.args.ScanProviderID, d.args.ScanSubscriptionID))
+}
+
+func (d *doctor) privileges() check {
+	const name = "Privileges"
+	if utilities.IsPrivileged() {
+		return pass(name, "running as an administrator")
+	}
+	return check{name: name, status: checkWarn, detail: "not running as root or Administrator",
+		hint: "Directories this user can't read are left out of the scan; the scheduled run has full privileges."}
+}
+
+func (d *doctor) stateDir() check {
+	const name = "State directory"
+	dir, err := utilities.StateDir()
+	if err != nil {
+		return fail(name, err, "Run as root or Administrator, or make the state directory writable.")
+	}
+	probe := filepath.Join(dir, ".doctor")
+	if err := os.WriteFile(probe, nil, 0600); err != nil {
+		return fail(name, fmt.Errorf("%s is not writable: %w", dir, err), "Run as root or Administrator, or make the state directory writable.")
+	}
+	os.Remove(probe)
+	return pass(name, dir)
+}
+
+func (d *doctor) tempSpace() check {
+	const name = "Temp space"
+	dir := os.TempDir()
+	free, err := utilities.FreeSpace(dir)
+	if err != nil {
+		return fail(name, err, "Set TMPDIR (TEMP on Windows) to a directory wiz-scan can write to.")
+	}
+	detail := fmt.Sprintf("%.1f GiB free in %s", float64(free)/(1<<30), dir)
+	if free < minTempSpace {
+		return fail(name, errors.New(detail), "wizcli and the scan results need at least 1 GiB; free up space or set TMPDIR (TEMP on Windows) elsewhere.")
+	}
+	return pass(name, detail)
+}
+
+func (d *doctor) directories() check {
+	const name = "Directories"
+	if d.args.ScannerReport != "" {
+		return skip(name, "reading "+d.args.ScannerReport+" instead of scanning")
+	}
+	directories := splitList(d.args.ScanPaths)
+	if len(directories) == 0 {
+		var err error
+		if directories, err = utilities.GetTopLevelDirectories(); err != nil {
+			return fail(name, err, "Set -scanPaths to the directories to scan.")
+		}
+	}
+	directories = removeExcluded(directories, splitList(d.args.Exclude))
+
+	var unreadable []string
+	for _, dir := range directories {
+		f, err := os.Open(dir)
+		if err == nil {
+			_, err = f.Readdirnames(1)
+			f.Close()
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			unreadable = append(unreadable, dir)
+		}
+	}
+	if len(unreadable) > 0 {
+		return fail(name, fmt.Errorf("%d of %d can't be read: %s", len(unreadable), len(directories), strings.Join(unreadable, ", ")),
+			"Run as root or Administrator, or leave them out with -exclude.")
+	}
+	return pass(name, fmt.Sprintf("%d readable", len(directories)))
+}
+
+func (d *doctor) scanner() check {
+	const name = "Scanner"
+	sc, err := scanner.New(d.args.Scanner, d.args.ScannerPath, d.args.ScannerReport)
+	if err != nil {
+		return fail(name, err, "Set -scanner to wizcli, trivy, grype or sbom.")
+	}
+	var binary string
+	switch s := sc.(type) {
+	case *scanner.TrivyScanner:
+		binary = s.BinaryPath
+	case *scanner.GrypeScanner:
+		binary = s.BinaryPath
+	}
+	if binary == "" || d.args.ScannerReport != "" {
+		return pass(name, sc.Name())
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return fail(name, fmt.Errorf("%s executable not found: %w", sc.Name(), err), "Install "+sc.Name()+" or point -scannerPath at it.")
+	}
+	return pass(name, fmt.Sprintf("%s at %s", sc.Name(), path))
+}
+
+// endpoint checks that rawURL resolves and accepts HTTPS connections, directly or through
+// the proxy. Any HTTP response counts, as only reaching the server is checked.
+func (d *doctor) endpoint(name, rawURL string) check {
+	if rawURL == "" {
+		return skip(name, "not configured")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return fail(name, fmt.Errorf("invalid URL %q", rawURL), "Copy the URL from the Wiz console (Tenant Info).")
+	}
+	request, err := http.NewRequest(http.MethodHead, rawURL, nil)
+	if err != nil {
+		return fail(name, err, "Copy the URL from the Wiz console (Tenant Info).")
+	}
+
+	proxy, _ := http.ProxyFromEnvironment(request)
+	if proxy == nil {
+		if _, err := net.LookupHost(u.Hostname()); err != nil {
+			return fail(name, err, "Check DNS on this host, or set -proxy when it can only reach the internet through a proxy.")
+		}
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		if proxy != nil {
+			return fail(name, err, fmt.Sprintf("Check that the proxy %s is reachable and allows %s.", proxy.Host, u.Host))
+		}
+		return fail(name, err, fmt.Sprintf("Allow outbound HTTPS to %s, or set -proxy.", u.Host))
+	}
+	response.Body.Close()
+	if proxy != nil {
+		return pass(name, fmt.Sprintf("%s reachable through %s", u.Host, proxy.Host))
+	}
+	return pass(name, u.Host+" reachable")
+}
+
+// usesWizCLI reports whether the scan engine is wizcli, which scanner.New matches in any case.
+func (d *doctor) usesWizCLI() bool {
+	return d.args.Scanner == "" || strings.EqualFold(d.args.Scanner, "wizcli")
+}
+
+func (d *doctor) wizcliEndpoint() check {
+	const name = "wizcli download"
+	if !d.usesWizCLI() {
+		return skip(name, "scanner is "+d.args.Scanner)
+	}
+	downloadURL, err := wizcli.GetDownloadURL()
+	if err != nil {
+		return fail(name, err, "Use trivy or grype as the scanner on this platform.")
+	}
+	return d.endpoint(name, downloadURL)
+}
+
+func (d *doctor) authentication() check {
+	const name = "Authentication"
+	if c, skipped := d.skipAfter(name, "Secret references", "Settings", "Auth endpoint"); skipped {
+		return c
+	}
+	api, err := wizapi.NewWizAPI(d.args.WizClientID, d.args.WizClientSecret, d.args.WizAuthURL, d.args.WizQueryURL)
+	if err != nil {
+		return fail(name, err, "Check the client ID and secret of the service account, and that the auth URL matches your tenant.")
+	}
+	d.api = api
+	return pass(name, "service account "+d.args.WizClientID)
+}
+
+// permissions reads the scopes the access token carries. Tokens that don't list them are
+// only checked by the asset lookup that follows.
+func (d *doctor) permissions() check {
+	const name = "Permissions"
+	if d.api == nil {
+		return skip(name, "not authenticated")
+	}
+	scopes, ok := tokenScopes(d.api.AuthToken)
+	if !ok {
+		return check{name: name, status: checkWarn, detail: "the access token doesn't list its scopes",
+			hint: "Make sure the service account has " + strings.Join(requiredScopes, ", ") + "."}
+	}
+	var missing []string
+	for _, scope := range requiredScopes {
+		if !scopes[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return check{name: name, status: checkWarn, detail: "the access token doesn't list " + strings.Join(missing, ", "),
+			hint: "If the asset lookup or a publish fails, grant the service account these permissions in the Wiz console (Settings > Service Accounts)."}
+	}
+	return pass(name, strings.Join(requiredScopes, ", "))
+}
+
+func (d *doctor) asset() check {
+	const name = "Asset"
+	if d.api == nil {
+		return skip(name, "not authenticated")
+	}
+	resourceID, err := d.api.GetResourceID(d.args.ScanCloudType, d.args.ScanProviderID)
+	if err != nil {
+		return fail(name, fmt.Errorf("%s was not found: %w", d.args.ScanProviderID, err),
+			"Check -scanProviderId and -scanCloudType against the asset in Wiz, and that it has been discovered by a cloud connector.")
+	}
+	return pass(name, fmt.Sprintf("%s is resource %s", d.args.ScanProviderID, resourceID))
+}
+
+// wizcli downloads wizcli, runs it and authenticates it, as a scan would.
+func (d *doctor) wizcli() check {
+	const name = "wizcli"
+	if !d.usesWizCLI() {
+		return skip(name, "scanner is "+d.args.Scanner)
+	}
+	if c, skipped := d.skipAfter(name, "wizcli download", "Temp space", "Authentication"); skipped {
+		return c
+	}
+	if d.api == nil {
+		return skip(name, "not authenticated")
+	}
+	path, err := wizcli.SetupEnvironment()
+	if err != nil {
+		return fail(name, err, "Check that the temp directory allows executables and the download isn't blocked by a proxy or antivirus.")
+	}
+	defer wizcli.CleanupEnvironment(path)
+
+	ctx, cancel := context.WithTimeout(context.Background(), wizcliVersionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "version").CombinedOutput()
+	if ctx.Err() != nil {
+		return fail(name, fmt.Errorf("wizcli version didn't finish within %s", wizcliVersionTimeout),
+			"An antivirus may be scanning the download, or wizcli is waiting on the network; check the proxy settings.")
+	}
+	if err != nil {
+		return fail(name, fmt.Errorf("downloaded wizcli doesn't run: %w", err),
+			"The temp directory may be mounted noexec, or the download was replaced by a proxy; set TMPDIR elsewhere.")
+	}
+	if err := os.Setenv("WIZ_DIR", filepath.Dir(path)); err != nil {
+		return fail(name, err, "")
+	}
+	if _, err := wizcli.AuthenticateWizcli(path, d.args.WizClientID, d.args.WizClientSecret); err != nil {
+		return fail(name, err, "The service account needs the scanner permissions of wizcli.")
+	}
+	version := strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	return pass(name, "downloaded, runs ("+version+") and authenticated")
+}
+
+func (d *doctor) scheduledTask() check {
+	const name = "Scheduled run"
+	installed, err := utilities.ScheduledTaskInstalled()
+	if err != nil {
+		return check{name: name, status: checkWarn, detail: err.Error(), hint: "Run doctor as root or Administrator to see the scheduled run."}
+	}
+	if !installed {
+		return check{name: name, status: checkWarn, detail: "no scheduled run on this host", hint: "Run `wiz-scan install` to scan daily."}
+	}
+	return pass(name, "installed")
+}
+
+// tokenScopes returns the scopes listed in the claims of a JWT access token.
+func tokenScopes(token string) (map[string]bool, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, false
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, false
+	}
+
+	scopes := make(map[string]bool)
+	for _, claim := range []string{"scope", "scp", "scopes", "permissions"} {
+		switch value := claims[claim].(type) {
+		case string:
+			for _, scope := range strings.Fields(value) {
+				scopes[scope] = true
+			}
+		case []interface{}:
+			for _, scope := range value {
+				scopes[fmt.Sprint(scope)] = true
+			}
+		}
+	}
+	return scopes, len(scopes) > 0
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jtb75/wiz-scan/pkg/history"
+	"github.com/jtb75/wiz-scan/pkg/report"
+	"github.com/jtb75/wiz-scan/pkg/utilities"
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+)
+
+// runHistory implements `wiz-scan history`, reporting on the findings recorded by earlier runs.
+func runHistory(arguments []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	list := fs.Bool("list", false, "List the recorded runs instead of comparing two of them")
+	from := fs.Uint64("from", 0, "Run to compare from (default: the run before -to)")
+	to := fs.Uint64("to", 0, "Run to compare to (default: the latest run)")
+	format := fs.String("format", "table", "Output format (table, json)")
+	if err := fs.Parse(arguments); err != nil {
+		return 2
+	}
+
+	dir, err := utilities.StateDir()
+	if err != nil {
+		log.Errorf("Failed to find the history database: %v", err)
+		return 1
+	}
+	store, err := history.Open(dir)
+	if err != nil {
+		log.Errorf("%v", err)
+		return 1
+	}
+	defer store.Close()
+
+	if *list {
+		runs, err := store.Runs()
+		if err == nil {
+			err = report.WriteRuns(os.Stdout, runs, *format)
+		}
+		if err != nil {
+			log.Errorf("Failed to list runs: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	diff, err := store.Diff(*from, *to)
+	if errors.Is(err, history.ErrNoRuns) {
+		fmt.Println("At least two recorded runs are needed to compare, see `wiz-scan history -list`.")
+		return 1
+	}
+	if err == nil {
+		err = report.WriteDiff(os.Stdout, diff, *format)
+	}
+	if err != nil {
+		log.Errorf("Failed to compare runs: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// recordHistory stores the findings of this run in the local history database.
+// Failing to record is logged but doesn't fail the run.
+func recordHistory(args *utilities.Arguments, verdicts []vulnerability.Verdict) {
+	dir, err := utilities.StateDir()
+	if err != nil {
+		log.Warnf("Not recording history: %v", err)
+		return
+	}
+	store, err := history.Open(dir)
+	if err != nil {
+		log.Warnf("Not recording history: %v", err)
+		return
+	}
+	defer store.Close()
+
+	hostname, _ := os.Hostname()
+	run, err := store.Record(history.Run{Time: time.Now(), HostName: hostname, ProviderID: args.ScanProviderID}, verdicts, args.HistoryRuns)
+	if err != nil {
+		log.Warnf("Not recording history: %v", err)
+		return
+	}
+	log.Debugf("Recorded run %d with %d findings in %s", run.ID, run.Findings, dir)
+}
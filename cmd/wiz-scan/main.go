@@ -14,12 +14,21 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
 	"time"
 
+	"github.com/jtb75/wiz-scan/pkg/glob"
+	"github.com/jtb75/wiz-scan/pkg/logging"
+	"github.com/jtb75/wiz-scan/pkg/policy"
+	"github.com/jtb75/wiz-scan/pkg/report"
+	"github.com/jtb75/wiz-scan/pkg/sbom"
+	"github.com/jtb75/wiz-scan/pkg/scanner"
+	"github.com/jtb75/wiz-scan/pkg/suppression"
 	"github.com/jtb75/wiz-scan/pkg/utilities"
 	"github.com/jtb75/wiz-scan/pkg/vulnerability"
 	"github.com/jtb75/wiz-scan/pkg/wizapi"
@@ -27,139 +36,164 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-var log = logrus.New()
-
-// Set testRun to "false", "genData", or "runTestData"
-var runTest = "false"
-
-func LogInit(level string) {
-	// Map string log level to logrus.Level
-	logLevel, err := logrus.ParseLevel(level)
+// log is the logger every package writes to, configured by LogInit.
+var log = logrus.StandardLogger()
+
+// LogInit applies the logging flags of args.
+func LogInit(args *utilities.Arguments) {
+	err := logging.Configure(log, logging.Options{
+		Level:      args.LogLevel,
+		Format:     args.LogFormat,
+		File:       args.LogFile,
+		MaxSizeMB:  args.LogMaxSize,
+		MaxAgeDays: args.LogMaxAge,
+		MaxBackups: args.LogMaxBackups,
+	})
 	if err != nil {
-		log.Fatalf("Invalid log level: %s", level)
+		log.Fatalf("Invalid logging configuration: %v", err)
 	}
-
-	log.SetLevel(logLevel)
 }
 
-func scanDirectories(drives []string, aggregatedResults *wizcli.AggregatedScanResults, operatingSystem string, wizCliPath string) error {
-	if runTest != "runTestData" {
-		for _, drive := range drives {
-			mountedPath := ""
-			shadowCopyID := ""
-			// If Windows, initiate VSS snapshot
-			if operatingSystem == "windows" {
-				var err error // Define err here
-				mountedPath, shadowCopyID, err = utilities.CreateVSSSnapshot(drive)
-				if err != nil {
-					log.Errorf("Error creating VSS snapshot for drive %s: %v", drive, err)
-					if err := RemoveSymbolicLink(mountedPath); err != nil {
-						log.Errorf("Failed to remove symbolic link: %v", err)
-					}
-					continue
-				} else {
-					log.Infof("Created VSS ID `%s` Mounted on: %s", shadowCopyID, mountedPath)
-				}
-			}
-			if mountedPath == "" {
-				mountedPath = drive
-			}
-			scanResult, err := wizcli.ScanDirectory(wizCliPath, mountedPath)
+func scanDirectories(drives []string, aggregatedResults *wizcli.AggregatedScanResults, operatingSystem string, sc scanner.Scanner) error {
+	for _, drive := range drives {
+		mountedPath := ""
+		shadowCopyID := ""
+		// If Windows, initiate VSS snapshot
+		if operatingSystem == "windows" {
+			var err error // Define err here
+			mountedPath, shadowCopyID, err = utilities.CreateVSSSnapshot(drive)
 			if err != nil {
-				log.Errorf("Failed to scan %s: %v", mountedPath, err)
+				log.Errorf("Error creating VSS snapshot for drive %s: %v", drive, err)
+				if err := RemoveSymbolicLink(mountedPath); err != nil {
+					log.Errorf("Failed to remove symbolic link: %v", err)
+				}
 				continue
 			} else {
-				log.Info("Scanned successfully")
-			}
-			// Prepend the Drive to the Library path to represent actual full path
-			for i, lib := range scanResult.Result.Libraries {
-				if runtime.GOOS == "windows" {
-					lib.Path = strings.ReplaceAll(lib.Path, "/", "\\")
-					lib.Path = strings.TrimPrefix(lib.Path, "\\")
-				}
-				scanResult.Result.Libraries[i].Path = drive + lib.Path
-			}
-			aggregatedResults.Libraries = append(aggregatedResults.Libraries, scanResult.Result.Libraries...)
-			aggregatedResults.Applications = append(aggregatedResults.Applications, scanResult.Result.Applications...)
-			// If Windows, clean up VSS snapshot
-			if operatingSystem == "windows" {
-				if err := utilities.RemoveVSSSnapshot(mountedPath, shadowCopyID); err != nil {
-					log.Errorf("Failed to remove mount and VSS snapshot for drive %s: %v", drive, err)
-				} else {
-					log.Infof("Removed mount and VSS snapshot for drive %s", drive)
-				}
+				log.Infof("Created VSS ID `%s` Mounted on: %s", shadowCopyID, mountedPath)
 			}
 		}
-	}
-	if runTest == "genData" {
-		jsonBytes, err := json.MarshalIndent(aggregatedResults, "", "    ")
-		if err != nil {
-			log.Errorln("Error marshalling JSON:", err)
+		if mountedPath == "" {
+			mountedPath = drive
 		}
-
-		err = os.WriteFile("sample_data/scan.json", jsonBytes, 0644)
-		if err != nil {
-			log.Errorln("Error writing to file:", err)
+		scanResult, err := sc.Scan(mountedPath)
+		// If Windows, clean up VSS snapshot
+		if operatingSystem == "windows" {
+			if err := utilities.RemoveVSSSnapshot(mountedPath, shadowCopyID); err != nil {
+				log.Errorf("Failed to remove mount and VSS snapshot for drive %s: %v", drive, err)
+			} else {
+				log.Infof("Removed mount and VSS snapshot for drive %s", drive)
+			}
 		}
-		os.Exit(0)
-	}
-	if runTest == "runTestData" {
-		// Read in sample data into aggregatedResults
-		log.Infoln("Reading in sample scan data")
-		data, err := os.ReadFile("sample_data/scan.json")
 		if err != nil {
-			log.Errorln("Error reading sample data file:", err)
-			return err
+			log.Errorf("Failed to scan %s with %s: %v", mountedPath, sc.Name(), err)
+			var scanErr *wizcli.ScanError
+			if errors.As(err, &scanErr) && scanErr.Outcome == wizcli.OutcomeAuthFailure {
+				return err
+			}
+			continue
+		} else {
+			log.Infof("Scanned %s successfully with %s", mountedPath, sc.Name())
+		}
+		// Prepend the Drive to the Library path to represent actual full path
+		for i, lib := range scanResult.Libraries {
+			scanResult.Libraries[i].Path = prependDrive(drive, lib.Path)
 		}
-		if err := json.Unmarshal(data, &aggregatedResults); err != nil {
-			log.Errorln("Error unmarshalling JSON:", err)
-			return err
+		for i, cpe := range scanResult.Cpes {
+			if cpe.Path != "" {
+				scanResult.Cpes[i].Path = prependDrive(drive, cpe.Path)
+			}
 		}
+		appendScanResults(aggregatedResults, scanResult)
 	}
 
 	return nil
 }
 
-func gatherWizKnownVulns(runTest string, wizAPI *wizapi.WizAPI, resourceID string) ([]wizapi.VulnerabilityNode, error) {
-	var response []wizapi.VulnerabilityNode
-	var err error
+func prependDrive(drive, path string) string {
+	if runtime.GOOS == "windows" {
+		path = strings.ReplaceAll(path, "/", "\\")
+		path = strings.TrimPrefix(path, "\\")
+	}
+	return drive + path
+}
 
-	if runTest == "genData" || runTest == "runTestData" {
-		if runTest == "genData" {
-			response, err = wizapi.FetchAllVulnerabilities(wizAPI, resourceID)
-			if err != nil {
-				return nil, fmt.Errorf("error fetching vulnerabilities: %v", err)
-			}
+// splitList splits a comma separated setting, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
 
-			jsonResponseBytes, err := json.MarshalIndent(response, "", "    ")
-			if err != nil {
-				return nil, fmt.Errorf("error marshalling JSON: %v", err)
-			}
+// excluded reports whether path, or a directory it is in, matches one of the patterns.
+func excluded(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimRight(pattern, "/\\")
+		if glob.Match(pattern, path, true) || glob.Match(pattern+"/**", path, true) {
+			return true
+		}
+	}
+	return false
+}
 
-			err = os.WriteFile("sample_data/known_vulns.json", jsonResponseBytes, 0644)
-			if err != nil {
-				return nil, fmt.Errorf("error writing to file: %v", err)
-			}
-		} else {
-			log.Infoln("Reading in sample known data")
-			data, err := os.ReadFile("sample_data/known_vulns.json")
-			if err != nil {
-				return nil, fmt.Errorf("failed to read sample data file: %v", err)
-			}
+func removeExcluded(directories, patterns []string) []string {
+	var kept []string
+	for _, dir := range directories {
+		if excluded(dir, patterns) {
+			log.Infof("Not scanning excluded directory %s", dir)
+			continue
+		}
+		kept = append(kept, dir)
+	}
+	return kept
+}
 
-			if err := json.Unmarshal(data, &response); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal sample data: %v", err)
-			}
+// excludeResults drops the libraries and CPEs found at excluded paths. Scanners search
+// directories recursively, so excluded subdirectories can only be removed afterwards.
+func excludeResults(results *wizcli.AggregatedScanResults, patterns []string) {
+	if len(patterns) == 0 {
+		return
+	}
+	libraries := results.Libraries[:0]
+	for _, lib := range results.Libraries {
+		if !excluded(lib.Path, patterns) {
+			libraries = append(libraries, lib)
 		}
-	} else {
-		// Fetch vulnerabilities directly if not in test mode
-		response, err = wizapi.FetchAllVulnerabilities(wizAPI, resourceID)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching vulnerabilities: %v", err)
+	}
+	cpes := results.Cpes[:0]
+	for _, cpe := range results.Cpes {
+		if cpe.Path == "" || !excluded(cpe.Path, patterns) {
+			cpes = append(cpes, cpe)
 		}
 	}
+	if dropped := len(results.Libraries) - len(libraries) + len(results.Cpes) - len(cpes); dropped > 0 {
+		log.Infof("Excluded %d components found at excluded paths", dropped)
+	}
+	results.Libraries = libraries
+	results.Cpes = cpes
+}
 
-	return response, nil
+// configureProxy routes the Wiz API and the wizcli download, which use the environment's
+// proxy settings, through the configured proxy. wizcli itself inherits them.
+func configureProxy(args *utilities.Arguments) {
+	if args.Proxy == "" {
+		return
+	}
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY"} {
+		if err := os.Setenv(name, args.Proxy); err != nil {
+			log.Warnf("Unable to set %s: %v", name, err)
+		}
+	}
+}
+
+func appendScanResults(aggregatedResults *wizcli.AggregatedScanResults, scanResult *wizcli.AggregatedScanResults) {
+	aggregatedResults.OsPackages = append(aggregatedResults.OsPackages, scanResult.OsPackages...)
+	aggregatedResults.Libraries = append(aggregatedResults.Libraries, scanResult.Libraries...)
+	aggregatedResults.Applications = append(aggregatedResults.Applications, scanResult.Applications...)
+	aggregatedResults.Cpes = append(aggregatedResults.Cpes, scanResult.Cpes...)
 }
 
 func RemoveSymbolicLink(path string) error {
@@ -173,13 +207,15 @@ func RemoveSymbolicLink(path string) error {
 
 func main() {
 
-	var assetVulns vulnerability.Asset
-
 	// Initialize logging with default Info level
-	LogInit("info") // Set default log level to Info
+	LogInit(&utilities.Arguments{LogLevel: "info"})
 
-	// Get the detected operating system
-	operatingSystem := runtime.GOOS
+	// Subcommands, the flags below stay the default for existing installs and scripts
+	if len(os.Args) > 1 {
+		if cmd := findCommand(os.Args[1]); cmd != nil {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
 
 	args, err := utilities.ProcessArguments() // Capture both the arguments and the error
 	if err != nil {
@@ -189,35 +225,350 @@ func main() {
 	}
 
 	// Set log level based on arguments
-	LogInit(args.LogLevel)
+	LogInit(args)
+	configureProxy(args)
+	if err := utilities.ResolveSecrets(args); err != nil {
+		log.Errorf("%v", err)
+		os.Exit(1)
+	}
 
 	// Print the detected operating system
-	log.Debug("Operating System:", operatingSystem)
+	log.Debug("Operating System:", runtime.GOOS)
 
 	// If uninstall flag is passed, initiate process
 	if args.Uninstall {
-		log.Info("Initiating Uninstall")
-		err := utilities.UninstallAndRemoveTask()
-		if err != nil {
-			fmt.Println("Error:", err)
-			return
-		}
-		fmt.Println("Uninstallation and task removal completed successfully.")
-		os.Exit(0)
+		os.Exit(uninstall())
 	}
 	// If install flag is passed, initiate process
 	if args.Install {
-		log.Info("Initiating Install")
-		err := utilities.InstallAndScheduleTask(args)
+		os.Exit(install(args))
+	}
+
+	os.Exit(run(args, stagePublish))
+}
+
+// stage is how far run takes a host through scanning, comparing and publishing.
+type stage int
+
+const (
+	stageScan stage = iota
+	stageCompare
+	stagePublish
+)
+
+// run scans the host and, depending on stage, compares the results with Wiz and publishes
+// them. It returns the process exit code.
+func run(args *utilities.Arguments, stage stage) int {
+	code, _ := runStage(args, stage)
+	return code
+}
+
+// runStage is run, also reporting whether the exit code is only the policy gate failing on
+// a run that otherwise completed, so the daemon doesn't retry it.
+func runStage(args *utilities.Arguments, stage stage) (int, bool) {
+	var err error
+	logging.SetPhase("setup")
+
+	// Load the policy up front so a broken file fails before scanning
+	var pol *policy.Policy
+	if args.Policy != "" {
+		pol, err = policy.Load(args.Policy)
+		if err != nil {
+			log.Errorf("Failed to load policy: %v", err)
+			return 1, false
+		}
+	}
+
+	var suppressions *suppression.List
+	if args.Suppressions != "" {
+		suppressions, err = suppression.Load(strings.Split(args.Suppressions, ","))
+		if err != nil {
+			log.Errorf("Failed to load suppressions: %v", err)
+			return 1, false
+		}
+		for _, expired := range suppressions.Expired() {
+			log.Warnf("Suppression expired, the vulnerability is reported again: %s", expired.String())
+		}
+		log.Infof("Loaded %d suppressions", suppressions.Len())
+	}
+
+	templates, err := loadTemplates(args.Templates)
+	if err != nil {
+		log.Errorf("Failed to load templates: %v", err)
+		return 1, false
+	}
+
+	exitCode := 0
+	// gateFailed is set by the policy gate and failed by anything else going wrong after it
+	gateFailed, failed := false, false
+
+	var wizAPI *wizapi.WizAPI
+	var response []wizapi.VulnerabilityNode
+	if stage != stageScan {
+		logging.SetPhase("fetch")
+		if args.KnownVulns != "" {
+			log.Infof("Reading known vulnerabilities from %s", args.KnownVulns)
+			response, err = loadKnownVulns(args.KnownVulns)
+		} else {
+			wizAPI, response, err = fetchKnownVulns(args)
+		}
+		if err != nil {
+			log.Errorf("Error gathering known vulnerabilities: %v", err)
+			return 1, false
+		}
+	}
+
+	logging.SetPhase("scan")
+	aggregatedResults := wizcli.AggregatedScanResults{}
+	if args.ScanResults != "" {
+		// Results saved by `wiz-scan scan -output`, possibly on another host
+		log.Infof("Reading scan results from %s", args.ScanResults)
+		scanResult, err := wizcli.LoadScanResults(args.ScanResults)
+		if err != nil {
+			log.Errorf("Error reading scan results: %v", err)
+			return 1, false
+		}
+		appendScanResults(&aggregatedResults, scanResult)
+	} else if code := scanHost(args, &aggregatedResults); code != 0 {
+		return code, false
+	}
+
+	excludeResults(&aggregatedResults, splitList(args.Exclude))
+
+	if args.SBOMOutput != "" {
+		hostname, _ := os.Hostname()
+		meta := sbom.Metadata{HostName: hostname, ToolVersion: Version, Timestamp: time.Now()}
+		if err := sbom.Export(args.SBOMOutput, args.SBOMFormat, &aggregatedResults, meta); err != nil {
+			log.Errorf("Error writing SBOM: %v", err)
+			// The SBOM is what a scan run produces, elsewhere it only accompanies the upload
+			if stage == stageScan {
+				return 1, false
+			}
+		} else {
+			log.Infof("SBOM written to %s", args.SBOMOutput)
+		}
+	}
+
+	if stage == stageScan {
+		if args.Output != "" {
+			if err := writeJSONOutput(args.Output, aggregatedResults); err != nil {
+				log.Errorf("Error writing scan results: %v", err)
+				return 1, false
+			}
+			log.Infof("Scan results written to %s", args.Output)
+		}
+		printScanSummary(summaryWriter(args), &aggregatedResults)
+		return 0, false
+	}
+
+	logging.SetPhase("compare")
+	assetVulns, verdicts, err := vulnerability.CompareVulnerabilities(aggregatedResults, response, args.ScanProviderID, vulnerability.Options{
+		Suppressions: suppressions,
+		Templates:    templates,
+	})
+	if err != nil {
+		log.Errorf("Error in CompareVulnerabilities: %v", err)
+		return 1, false
+	}
+
+	if pol != nil {
+		result := pol.Apply(&assetVulns, verdicts)
+		log.Infof("Policy dropped %d and downgraded %d findings, %d violations", result.Dropped, result.Downgraded, len(result.Violations))
+		for _, v := range result.Violations {
+			log.Warnf("Policy violation (%s): %s in %s %s [%s]", v.Rule, v.CVE, v.Package, v.Path, v.Severity)
+		}
+		if len(result.Violations) > pol.Gate.MaxViolations {
+			log.Errorf("Policy gate failed: %d violations exceed the allowed %d", len(result.Violations), pol.Gate.MaxViolations)
+			exitCode = pol.Gate.ExitCode
+			gateFailed = true
+		}
+	}
+
+	if dropped := vulnerability.DropDuplicateFindings(&assetVulns); dropped > 0 {
+		log.Warnf("Dropped %d duplicate findings", dropped)
+	}
+
+	if args.Report != "" || stage == stageCompare {
+		if args.ReportFile == "" {
+			err = report.WriteVerdicts(summaryWriter(args), verdicts, args.Report)
+		} else {
+			err = report.WriteVerdictsFile(args.ReportFile, verdicts, args.Report)
+		}
+		if err != nil {
+			log.Errorf("Error writing comparison report: %v", err)
+		}
+	}
+
+	if stage == stageCompare {
+		if args.Output != "" {
+			vulnPayload := newPayload(args, assetVulns)
+			if err := vulnerability.Validate(vulnPayload); err != nil {
+				log.Warnf("The payload couldn't be published as is: %v", err)
+			}
+			if err := writeJSONOutput(args.Output, vulnPayload); err != nil {
+				log.Errorf("Error writing payload: %v", err)
+				return 1, false
+			}
+			log.Infof("Payload of %d findings written to %s", len(assetVulns.VulnerabilityFindings), args.Output)
+		}
+		return exitCode, gateFailed && !failed
+	}
+
+	// Chunks a previous run uploaded beyond today's count still hold findings, so they are
+	// published empty, even when nothing was found this time
+	chunkKey := args.ScanSubscriptionID + "/" + args.ScanProviderID
+	previousChunks := make(map[string]int)
+	if err := utilities.ReadState(chunkStateFile, &previousChunks); err != nil {
+		log.Warnf("Unable to read the previous upload count, stale chunks won't be cleared: %v", err)
+	}
+	nothingToPublish := len(assetVulns.VulnerabilityFindings) == 0 && previousChunks[chunkKey] == 0
+
+	// The history is of this host's published runs, so results scanned elsewhere, dry runs
+	// and runs whose upload failed stay out of it
+	recordPublished := func() {
+		if args.History && args.ScanResults == "" {
+			recordHistory(args, verdicts)
+		}
+	}
+
+	// The reports show an empty run too, so only skip building the payload when nothing reads it
+	if nothingToPublish && !args.DryRun && args.FindingsOutput == "" {
+		log.Infof("No new vulnerabilities found")
+		recordPublished()
+		return exitCode, gateFailed && !failed
+	}
+
+	logging.SetPhase("publish")
+	vulnPayload := newPayload(args, assetVulns)
+	dataSource := vulnPayload.DataSources[0]
+
+	if err := vulnerability.Validate(vulnPayload); err != nil {
+		log.Errorf("Not publishing vulnerabilities, the payload is invalid: %v", err)
+		return 1, false
+	}
+
+	if args.FindingsOutput != "" {
+		hostname, _ := os.Hostname()
+		meta := report.Metadata{HostName: hostname, ToolVersion: Version, Timestamp: dataSource.AnalysisDate}
+		if err := report.WriteFindingsOutputs(args.FindingsOutput, vulnPayload, meta); err != nil {
+			log.Errorf("Error writing findings report: %v", err)
+			exitCode = 1
+			failed = true
+		}
+	}
+
+	chunks, err := vulnerability.Chunk(vulnPayload, args.ChunkMaxFindings, args.ChunkMaxBytes)
+	if err != nil {
+		log.Errorf("Error splitting vulnerabilities into uploads: %v", err)
+		return 1, false
+	}
+
+	if args.DryRun {
+		log.Infof("Dry run, not publishing %d findings in %d uploads", len(assetVulns.VulnerabilityFindings), len(chunks))
+		return exitCode, gateFailed && !failed
+	}
+
+	if nothingToPublish {
+		log.Infof("No new vulnerabilities found")
+		recordPublished()
+		return exitCode, gateFailed && !failed
+	}
+
+	uploads := len(chunks)
+	for i := len(chunks); i < previousChunks[chunkKey]; i++ {
+		chunks = append(chunks, vulnerability.EmptyChunk(vulnPayload, i, dataSource.Assets[0].AssetIdentifier))
+	}
+	if uploads == 0 {
+		log.Infof("No new vulnerabilities found, clearing the %d uploads of the previous run", len(chunks))
+	} else if len(chunks) > 1 {
+		log.Infof("Publishing %d findings in %d uploads", len(assetVulns.VulnerabilityFindings), len(chunks))
+	}
+
+	total := &wizapi.PublishResult{}
+	uploaded := 0
+	for i, chunk := range chunks {
+		result, err := publishChunk(wizAPI, chunk)
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			log.Errorf("Error publishing vulnerabilities (upload %d of %d): %v", i+1, len(chunks), err)
+			exitCode = 1
+			failed = true
+			break
+		}
+		total.Add(result)
+		uploaded++
+	}
+	if len(chunks) > 1 {
+		log.Infof("Published %d of %d uploads, status %s: %d of %d findings handled, %d unresolved assets",
+			uploaded, len(chunks), total.Status, total.Findings.Handled, total.Findings.Incoming, total.UnresolvedAssetsCount)
+	}
+
+	// Only forget stale chunks once they were all cleared
+	if uploaded == len(chunks) {
+		previousChunks[chunkKey] = uploads
+		if err := utilities.WriteState(chunkStateFile, previousChunks); err != nil {
+			log.Warnf("Unable to record the upload count: %v", err)
 		}
-		fmt.Println("Installation and task scheduling completed successfully.")
-		os.Exit(0)
+		recordPublished()
+	}
+	return exitCode, gateFailed && !failed
+}
+
+// scanHost scans this host with the configured scanner, or reads its existing report, into
+// aggregatedResults. It returns a non-zero exit code when the scan can't be done.
+func scanHost(args *utilities.Arguments, aggregatedResults *wizcli.AggregatedScanResults) int {
+	sc, err := scanner.New(args.Scanner, args.ScannerPath, args.ScannerReport)
+	if err != nil {
+		log.Errorf("Failed to create scanner: %v", err)
+		return 1
 	}
 
-	// Create a new instance of WizAPI
+	// Initialize and authenticate wizcli when it is the scan engine
+	if wizScanner, ok := sc.(*scanner.WizCLIScanner); ok {
+		cleanup, wizCliPath, err := wizcli.InitializeAndAuthenticate(args.WizClientID, args.WizClientSecret)
+		if err != nil {
+			log.Errorf("initialization and authentication failed: %v", err)
+			return 1
+		}
+		defer cleanup()
+		wizScanner.BinaryPath = wizCliPath
+	}
+
+	if args.ScannerReport != "" {
+		// An existing report already covers the host, so there is nothing to scan
+		log.Infof("Reading %s report %s", sc.Name(), args.ScannerReport)
+		scanResult, err := sc.Scan("")
+		if err != nil {
+			log.Errorf("Error reading %s report: %v", sc.Name(), err)
+			return 1
+		}
+		appendScanResults(aggregatedResults, scanResult)
+		return 0
+	}
+
+	// Retrieve top-level directories, unless the configuration names them
+	directories := splitList(args.ScanPaths)
+	if len(directories) == 0 {
+		directories, err = utilities.GetTopLevelDirectories()
+		if err != nil {
+			log.Errorf("Error listing directories: %v", err)
+			return 1
+		}
+	}
+	directories = removeExcluded(directories, splitList(args.Exclude))
+	log.Debug("Directories to scan: ", directories)
+
+	log.Info("Initiating directory scan")
+	// Cycle through directories and initiate scan
+	if err := scanDirectories(directories, aggregatedResults, runtime.GOOS, sc); err != nil {
+		log.Errorf("Error scanning directories: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// fetchKnownVulns returns the vulnerabilities Wiz already knows on the asset, together with
+// the API client for publishing.
+func fetchKnownVulns(args *utilities.Arguments) (*wizapi.WizAPI, []wizapi.VulnerabilityNode, error) {
 	wizAPI, err := wizapi.NewWizAPI(
 		args.WizClientID,
 		args.WizClientSecret,
@@ -225,95 +576,89 @@ func main() {
 		args.WizQueryURL,
 	)
 	if err != nil {
-		log.Errorf("Failed to create WizAPI instance: %v", err)
-		os.Exit(1)
+		return nil, nil, fmt.Errorf("failed to create WizAPI instance: %w", err)
 	}
-	log.Debugf("Auth Token: %s", wizAPI.AuthToken)
 
-	// Call GetResourceID method using args.ScanCloudType and args.ScanProviderID
 	resourceID, err := wizAPI.GetResourceID(args.ScanCloudType, args.ScanProviderID)
 	if err != nil {
-		log.Errorf("Failed to get resource ID: %v", err)
-		os.Exit(1)
+		return nil, nil, fmt.Errorf("failed to get resource ID: %w", err)
 	}
 	log.Debugf("Matched Resource ID: %s", resourceID)
 
 	log.Info("Gathering known vulnerabilities from Wiz platform")
-	response, err := gatherWizKnownVulns(runTest, wizAPI, resourceID)
+	response, err := wizapi.FetchAllVulnerabilities(wizAPI, resourceID)
 	if err != nil {
-		log.Errorf("Error gathering known vulnerabilities: %v", err)
-		return
+		return nil, nil, fmt.Errorf("error fetching vulnerabilities: %w", err)
 	}
+	return wizAPI, response, nil
+}
 
-	// Initialize and authenticate wizcli
-	cleanup, wizCliPath, err := wizcli.InitializeAndAuthenticate(args.WizClientID, args.WizClientSecret)
+// loadKnownVulns reads known vulnerabilities saved by `wiz-scan fetch-known -output`.
+func loadKnownVulns(filePath string) ([]wizapi.VulnerabilityNode, error) {
+	data, err := os.ReadFile(filePath)
 	if err != nil {
-		log.Errorf("initialization and authentication failed: %v", err)
-		return
+		return nil, err
 	}
-	defer cleanup()
-
-	// Retrieve top-level directories
-	directories, err := utilities.GetTopLevelDirectories()
-	if err != nil {
-		log.Errorf("Error listing directories: %v", err)
-		return
-	} else {
-		log.Debug("Directories to scan: ", directories)
+	var response []wizapi.VulnerabilityNode
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse known vulnerabilities %s: %w", filePath, err)
 	}
+	return response, nil
+}
 
-	aggregatedResults := wizcli.AggregatedScanResults{}
-
-	log.Info("Initiating directory scan")
-	// Cycle through directories and initiate scan
-	//directories = []string{"E:\\"}
-	if err := scanDirectories(directories, &aggregatedResults, operatingSystem, wizCliPath); err != nil {
-		log.Errorf("Error scanning directories: %v", err)
-		return
+// newPayload wraps the findings of the asset in the payload published to Wiz.
+func newPayload(args *utilities.Arguments, assetVulns vulnerability.Asset) vulnerability.IntegrationData {
+	assetVulns.AssetIdentifier.CloudPlatform = args.ScanCloudType
+	assetVulns.AssetIdentifier.ProviderId = args.ScanProviderID
+	return vulnerability.IntegrationData{
+		IntegrationId: "e4341955-463f-4228-aa99-a718e9d93bb5", // Set an integration ID
+		DataSources: []vulnerability.DataSource{{
+			Id:           args.ScanSubscriptionID,
+			AnalysisDate: time.Now(),
+			Assets:       []vulnerability.Asset{assetVulns},
+		}},
 	}
+}
 
-	assetVulns, err = vulnerability.CompareVulnerabilities(aggregatedResults, response, args.ScanProviderID)
+// writeJSONOutput writes v as indented JSON to filePath, or to stdout for "-".
+func writeJSONOutput(filePath string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
 	if err != nil {
-		fmt.Printf("Error in CompareVulnerabilities: %s\n", err)
-		return
+		return fmt.Errorf("error marshalling JSON: %w", err)
 	}
+	if filePath == "-" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
 
-	var vulnPayloadJSON []byte // Use a byte slice to hold JSON data
-
-	if len(assetVulns.VulnerabilityFindings) > 0 {
-		assetVulns.AssetIdentifier.CloudPlatform = args.ScanCloudType
-		assetVulns.AssetIdentifier.ProviderId = args.ScanProviderID
-		vulnPayload := vulnerability.IntegrationData{
-			IntegrationId: "e4341955-463f-4228-aa99-a718e9d93bb5", // Set an integration ID
-			DataSources:   []vulnerability.DataSource{},           // Initialize an empty slice of DataSources
-		}
-		// Create a DataSource and add assetVulns to it
-		dataSource := vulnerability.DataSource{
-			Id:           args.ScanSubscriptionID,
-			AnalysisDate: time.Now(),                        // Set current time as the analysis date
-			Assets:       []vulnerability.Asset{assetVulns}, // Add assetVulns here
-		}
+// summaryWriter is where the summary or report of a run is printed: stdout, unless -output -
+// has it for the JSON, which then has to be the only thing written there.
+func summaryWriter(args *utilities.Arguments) io.Writer {
+	if args.Output == "-" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
 
-		vulnPayload.DataSources = append(vulnPayload.DataSources, dataSource)
+// chunkStateFile records how many uploads the last run used per data source and provider.
+const chunkStateFile = "chunks.json"
 
-		vulnPayloadJSON, err = json.MarshalIndent(vulnPayload, "", "\t")
-		if err != nil {
-			fmt.Println("Error marshaling assetVulns to JSON:", err)
-			return
-		}
-	} else {
-		log.Infof("No new vulnerabilities found")
-		return // Exit the program gracefully
+// publishChunk writes one payload to a temporary file and publishes it.
+func publishChunk(wizAPI *wizapi.WizAPI, payload vulnerability.IntegrationData) (*wizapi.PublishResult, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling payload to JSON: %w", err)
 	}
 
 	file, err := utilities.CreateTempFile()
 	if err != nil {
-		log.Errorln("Error creating temp file:", err)
-		return
+		return nil, fmt.Errorf("error creating temp file: %w", err)
 	}
 
 	defer func() {
-		// Ensure the temporary file is deleted upon exiting the function
+		// Ensure the temporary file is deleted once published
 		if err := file.Close(); err != nil {
 			log.Errorf("Error closing file: %v", err)
 		}
@@ -323,15 +668,24 @@ func main() {
 	}()
 
 	log.Infoln("Temporary file created:", file.Name())
-	_, err = file.Write(vulnPayloadJSON)
-
-	if err != nil {
-		log.Errorln("Error writing JSON to temp file:", err)
-		return
+	if _, err := file.Write(payloadJSON); err != nil {
+		return nil, fmt.Errorf("error writing JSON to temp file: %w", err)
 	}
 
-	if err := wizAPI.PublishVulns(file.Name()); err != nil {
-		log.Errorln("Error publishing vulnerabilities:", err)
-	}
+	return wizAPI.PublishVulns(file.Name())
+}
 
+// loadTemplates compiles the templates section of the configuration file over the defaults.
+func loadTemplates(settings *utilities.TemplateSettings) (*vulnerability.Templates, error) {
+	if settings == nil {
+		return vulnerability.DefaultTemplates()
+	}
+	text := func(t utilities.TemplateText) vulnerability.TextTemplates {
+		return vulnerability.TextTemplates{Description: t.Description, Remediation: t.Remediation}
+	}
+	return vulnerability.NewTemplates(vulnerability.TemplateConfig{
+		Library:     text(settings.Library),
+		Application: text(settings.Application),
+		OS:          text(settings.OS),
+	})
 }
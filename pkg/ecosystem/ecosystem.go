@@ -0,0 +1,184 @@
+package ecosystem
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Ecosystem identifies the package manager a package belongs to.
+// The values are the matching package URL types.
+type Ecosystem string
+
+const (
+	Unknown  Ecosystem = ""
+	Maven    Ecosystem = "maven"
+	Npm      Ecosystem = "npm"
+	PyPI     Ecosystem = "pypi"
+	NuGet    Ecosystem = "nuget"
+	Go       Ecosystem = "golang"
+	RubyGems Ecosystem = "gem"
+	Cargo    Ecosystem = "cargo"
+	Composer Ecosystem = "composer"
+	Debian   Ecosystem = "deb"
+	RPM      Ecosystem = "rpm"
+	Alpine   Ecosystem = "apk"
+)
+
+// pathHints maps path fragments to the ecosystem that installs files there, checked in order.
+// A guess is a hint that files of other ecosystems share, such as native DLLs or a directory
+// named requirements, and only used when no other hint matches.
+var pathHints = []struct {
+	fragment  string
+	ecosystem Ecosystem
+	guess     bool
+}{
+	{"node_modules", Npm, false},
+	{"package.json", Npm, false},
+	{"package-lock.json", Npm, false},
+	{"yarn.lock", Npm, false},
+	{"site-packages", PyPI, false},
+	{"dist-packages", PyPI, false},
+	{".dist-info", PyPI, false},
+	{".egg-info", PyPI, false},
+	{".whl", PyPI, false},
+	{"requirements", PyPI, true},
+	{"pipfile", PyPI, false},
+	{"poetry.lock", PyPI, false},
+	{".jar", Maven, false},
+	{".war", Maven, false},
+	{".ear", Maven, false},
+	{"pom.xml", Maven, false},
+	{".m2", Maven, false},
+	{".nupkg", NuGet, false},
+	{".deps.json", NuGet, false},
+	{"packages.config", NuGet, false},
+	{".csproj", NuGet, false},
+	{".dll", NuGet, true},
+	{"go.mod", Go, false},
+	{"go.sum", Go, false},
+	{"gemfile", RubyGems, false},
+	{".gemspec", RubyGems, false},
+	{"/gems/", RubyGems, false},
+	{"cargo.lock", Cargo, false},
+	{".cargo", Cargo, false},
+	{"composer.lock", Composer, false},
+	{"composer.json", Composer, false},
+}
+
+// DetectLibrary infers the ecosystem of a library from its name and the path it was found at.
+// The answer may be a guess, see KnownLibraryEcosystem.
+func DetectLibrary(name, path string) Ecosystem {
+	eco, _ := detectLibrary(name, path)
+	return eco
+}
+
+// KnownLibraryEcosystem returns the ecosystem of a library when its path leaves no doubt, and
+// Unknown when DetectLibrary could only guess it from a shared hint or from the name. Decisions
+// that drop a finding, such as whether it is already fixed, should only rely on this.
+func KnownLibraryEcosystem(name, path string) Ecosystem {
+	if eco, certain := detectLibrary(name, path); certain {
+		return eco
+	}
+	return Unknown
+}
+
+func detectLibrary(name, path string) (Ecosystem, bool) {
+	lowerPath := strings.ToLower(filepath.ToSlash(path))
+	for _, guess := range []bool{false, true} {
+		for _, hint := range pathHints {
+			if hint.guess == guess && strings.Contains(lowerPath, hint.fragment) {
+				return hint.ecosystem, !guess
+			}
+		}
+	}
+
+	// Fall back on naming conventions when the path gives nothing away
+	switch {
+	case strings.Count(name, ":") == 1:
+		return Maven, false
+	case strings.HasPrefix(name, "@") && strings.Contains(name, "/"):
+		return Npm, false
+	case strings.Contains(name, ".") && strings.Contains(name, "/"):
+		return Go, false
+	}
+	return Unknown, false
+}
+
+var (
+	osOnce      sync.Once
+	osEcosystem Ecosystem
+	osDistro    string
+	osRelease   string
+)
+
+// DetectOS returns the package ecosystem of the running operating system and its
+// distribution ID (e.g. "ubuntu"), read from /etc/os-release.
+func DetectOS() (Ecosystem, string) {
+	osOnce.Do(func() {
+		if runtime.GOOS != "linux" {
+			return
+		}
+		osEcosystem, osDistro, osRelease = parseOSRelease("/etc/os-release")
+	})
+	return osEcosystem, osDistro
+}
+
+// DetectOSRelease returns the distribution and release of the running operating system
+// in the style of package URL distro qualifiers, such as "debian-12" or "alpine-3.19.1",
+// or "" when it is unknown.
+func DetectOSRelease() string {
+	DetectOS()
+	return osRelease
+}
+
+func parseOSRelease(path string) (Ecosystem, string, string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Unknown, "", ""
+	}
+	defer file.Close()
+
+	var id, idLike, versionID, codename string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			id = strings.ToLower(value)
+		case "ID_LIKE":
+			idLike = strings.ToLower(value)
+		case "VERSION_ID":
+			versionID = value
+		case "VERSION_CODENAME":
+			codename = value
+		}
+	}
+
+	// Rolling releases such as Debian testing have a codename but no VERSION_ID
+	release := ""
+	if id != "" && versionID != "" {
+		release = id + "-" + versionID
+	} else if id != "" && codename != "" {
+		release = id + "-" + codename
+	}
+
+	for _, candidate := range append([]string{id}, strings.Fields(idLike)...) {
+		switch candidate {
+		case "debian", "ubuntu":
+			return Debian, id, release
+		case "rhel", "fedora", "centos", "amzn", "suse", "sles", "opensuse", "rocky", "almalinux", "ol", "mariner", "azurelinux":
+			return RPM, id, release
+		case "alpine":
+			return Alpine, id, release
+		}
+	}
+	return Unknown, id, release
+}
@@ -0,0 +1,669 @@
+package ecosystem
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Compare compares two versions using the rules of the ecosystem and returns
+// -1 if a is lower than b, 0 if they are equivalent and +1 if a is higher.
+func Compare(eco Ecosystem, a, b string) int {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	switch eco {
+	case Npm, Go, Cargo, Composer:
+		return compareSemver(a, b, false)
+	case NuGet:
+		return compareSemver(a, b, true)
+	case PyPI:
+		return comparePEP440(a, b)
+	case Maven:
+		return compareMaven(a, b)
+	case Debian:
+		return compareDebian(a, b)
+	case RPM:
+		return compareRPM(a, b)
+	case Alpine:
+		return compareAPK(a, b)
+	default:
+		return compareSemver(a, b, false)
+	}
+}
+
+// Equal reports whether two versions are equivalent in the ecosystem, e.g. 1.2.3 and v1.2.3.
+// An empty version is only equal to another empty version.
+func Equal(eco Ecosystem, a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == "" || b == "" {
+		return a == b
+	}
+	return a == b || Compare(eco, a, b) == 0
+}
+
+// IsFixed reports whether the installed version is at or above the fixed version. It only answers
+// true when the ecosystem is known and the fixed version is a single version rather than a list
+// of fixes for several release branches, so an unclear case is always treated as vulnerable.
+func IsFixed(eco Ecosystem, installed, fixed string) bool {
+	installed, fixed = strings.TrimSpace(installed), strings.TrimSpace(fixed)
+	if eco == Unknown || installed == "" || fixed == "" || strings.ContainsAny(fixed, ", |<>=") {
+		return false
+	}
+	return Compare(eco, installed, fixed) >= 0
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// compareNumeric compares two strings of digits without overflowing on long numbers.
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Semantic versioning, also used for NuGet which allows four release parts and ignores case.
+
+var semverPattern = regexp.MustCompile(`^[vV=]?(\d+(?:\.\d+)*)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+func compareSemver(a, b string, ignoreCase bool) int {
+	ma := semverPattern.FindStringSubmatch(a)
+	mb := semverPattern.FindStringSubmatch(b)
+	if ma == nil || mb == nil {
+		// Not semver shaped, so compare segment by segment like rpm does
+		return compareRPM(strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v"))
+	}
+
+	// Missing release parts count as zero so 1.2 equals 1.2.0
+	ra, rb := strings.Split(ma[1], "."), strings.Split(mb[1], ".")
+	for i := 0; i < len(ra) || i < len(rb); i++ {
+		pa, pb := "0", "0"
+		if i < len(ra) {
+			pa = ra[i]
+		}
+		if i < len(rb) {
+			pb = rb[i]
+		}
+		if c := compareNumeric(pa, pb); c != 0 {
+			return c
+		}
+	}
+
+	preA, preB := ma[2], mb[2]
+	if ignoreCase {
+		preA, preB = strings.ToLower(preA), strings.ToLower(preB)
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+
+	ia, ib := strings.Split(preA, "."), strings.Split(preB, ".")
+	for i := 0; i < len(ia) && i < len(ib); i++ {
+		na, nb := isNumeric(ia[i]), isNumeric(ib[i])
+		var c int
+		switch {
+		case na && nb:
+			c = compareNumeric(ia[i], ib[i])
+		case na:
+			c = -1
+		case nb:
+			c = 1
+		default:
+			c = strings.Compare(ia[i], ib[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return sign(len(ia) - len(ib))
+}
+
+// PEP 440 versions used by Python packages.
+
+var pep440Pattern = regexp.MustCompile(`^v?(?:(\d+)!)?(\d+(?:\.\d+)*)` +
+	`(?:[-_.]?(a|alpha|b|beta|c|rc|pre|preview)[-_.]?(\d*))?` +
+	`(?:-(\d+)|[-_.]?(post|rev|r)[-_.]?(\d*))?` +
+	`(?:[-_.]?(dev)[-_.]?(\d*))?` +
+	`(?:\+[a-z0-9]+(?:[-_.][a-z0-9]+)*)?$`)
+
+type pep440Version struct {
+	epoch   int
+	release []string
+	// pre orders the pre-release phase: -1 for dev-only releases, 0-2 for a/b/rc and 3 for none
+	pre    int
+	preNum int
+	// post is -1 when there is no post-release
+	post int
+	// dev is -1 when there is no dev-release
+	dev int
+}
+
+func parsePEP440(s string) (pep440Version, bool) {
+	m := pep440Pattern.FindStringSubmatch(strings.ToLower(s))
+	if m == nil {
+		return pep440Version{}, false
+	}
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	v := pep440Version{epoch: atoi(m[1]), pre: 3, post: -1, dev: -1}
+	v.release = strings.Split(m[2], ".")
+	for len(v.release) > 1 && strings.TrimLeft(v.release[len(v.release)-1], "0") == "" {
+		v.release = v.release[:len(v.release)-1]
+	}
+	switch m[3] {
+	case "a", "alpha":
+		v.pre = 0
+	case "b", "beta":
+		v.pre = 1
+	case "c", "rc", "pre", "preview":
+		v.pre = 2
+	}
+	v.preNum = atoi(m[4])
+	if m[5] != "" {
+		v.post = atoi(m[5])
+	} else if m[6] != "" {
+		v.post = atoi(m[7])
+	}
+	if m[8] != "" {
+		v.dev = atoi(m[9])
+		if m[3] == "" && v.post < 0 {
+			// 1.0.dev1 sorts before 1.0a1
+			v.pre = -1
+		}
+	}
+	return v, true
+}
+
+func comparePEP440(a, b string) int {
+	va, okA := parsePEP440(a)
+	vb, okB := parsePEP440(b)
+	if !okA || !okB {
+		return compareRPM(a, b)
+	}
+
+	if c := sign(va.epoch - vb.epoch); c != 0 {
+		return c
+	}
+	for i := 0; i < len(va.release) || i < len(vb.release); i++ {
+		pa, pb := "0", "0"
+		if i < len(va.release) {
+			pa = va.release[i]
+		}
+		if i < len(vb.release) {
+			pb = vb.release[i]
+		}
+		if c := compareNumeric(pa, pb); c != 0 {
+			return c
+		}
+	}
+	if c := sign(va.pre - vb.pre); c != 0 {
+		return c
+	}
+	if c := sign(va.preNum - vb.preNum); c != 0 {
+		return c
+	}
+	if c := sign(va.post - vb.post); c != 0 {
+		return c
+	}
+	// No dev-release sorts after any dev-release
+	devA, devB := va.dev, vb.dev
+	if devA < 0 {
+		devA = int(^uint(0) >> 1)
+	}
+	if devB < 0 {
+		devB = int(^uint(0) >> 1)
+	}
+	return sign(devA - devB)
+}
+
+// Maven versions, following the ordering of Maven's ComparableVersion.
+
+// mavenQualifiers orders the well known qualifiers; the release itself has the empty qualifier.
+var mavenQualifiers = map[string]int{
+	"alpha":     0,
+	"a":         0,
+	"beta":      1,
+	"b":         1,
+	"milestone": 2,
+	"m":         2,
+	"rc":        3,
+	"cr":        3,
+	"snapshot":  4,
+	"":          5,
+	"ga":        5,
+	"final":     5,
+	"release":   5,
+	"sp":        6,
+}
+
+type mavenItem struct {
+	number  string
+	text    string
+	numeric bool
+}
+
+func tokenizeMaven(s string) []mavenItem {
+	var items []mavenItem
+	var current strings.Builder
+	currentNumeric := false
+
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		token := current.String()
+		if currentNumeric {
+			items = append(items, mavenItem{number: token, numeric: true})
+		} else {
+			items = append(items, mavenItem{text: strings.ToLower(token)})
+		}
+		current.Reset()
+	}
+
+	for _, r := range s {
+		switch {
+		case r == '.' || r == '-' || r == '_':
+			flush()
+		case unicode.IsDigit(r):
+			if current.Len() > 0 && !currentNumeric {
+				flush()
+			}
+			currentNumeric = true
+			current.WriteRune(r)
+		default:
+			if current.Len() > 0 && currentNumeric {
+				flush()
+			}
+			currentNumeric = false
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	// Trailing zeros and release qualifiers don't change the version: 1.0.0-final equals 1
+	for len(items) > 0 {
+		last := items[len(items)-1]
+		if (last.numeric && strings.TrimLeft(last.number, "0") == "") || (!last.numeric && mavenQualifiers[last.text] == 5 && last.text != "") {
+			items = items[:len(items)-1]
+			continue
+		}
+		break
+	}
+	return items
+}
+
+func compareMavenItem(a, b mavenItem) int {
+	switch {
+	case a.numeric && b.numeric:
+		return compareNumeric(a.number, b.number)
+	case a.numeric:
+		// A number is newer than any qualifier
+		return 1
+	case b.numeric:
+		return -1
+	}
+
+	ra, knownA := mavenQualifiers[a.text]
+	rb, knownB := mavenQualifiers[b.text]
+	switch {
+	case knownA && knownB:
+		return sign(ra - rb)
+	case knownA:
+		// Unknown qualifiers sort after the known ones
+		return -1
+	case knownB:
+		return 1
+	}
+	return strings.Compare(a.text, b.text)
+}
+
+func compareMaven(a, b string) int {
+	ia, ib := tokenizeMaven(a), tokenizeMaven(b)
+	for i := 0; i < len(ia) || i < len(ib); i++ {
+		// A missing item is the release: 0 when compared with a number, "" with a qualifier
+		var pa, pb mavenItem
+		if i < len(ia) {
+			pa = ia[i]
+		} else if i < len(ib) && ib[i].numeric {
+			pa = mavenItem{number: "0", numeric: true}
+		}
+		if i < len(ib) {
+			pb = ib[i]
+		} else if i < len(ia) && ia[i].numeric {
+			pb = mavenItem{number: "0", numeric: true}
+		}
+		if c := compareMavenItem(pa, pb); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// Debian versions ([epoch:]upstream[-revision]), following dpkg's verrevcmp.
+
+func splitEpoch(s string) (int, string) {
+	if i := strings.Index(s, ":"); i >= 0 {
+		if epoch, err := strconv.Atoi(s[:i]); err == nil {
+			return epoch, s[i+1:]
+		}
+	}
+	return 0, s
+}
+
+func compareDebian(a, b string) int {
+	epochA, restA := splitEpoch(a)
+	epochB, restB := splitEpoch(b)
+	if c := sign(epochA - epochB); c != 0 {
+		return c
+	}
+
+	upstreamA, revisionA := restA, ""
+	if i := strings.LastIndex(restA, "-"); i >= 0 {
+		upstreamA, revisionA = restA[:i], restA[i+1:]
+	}
+	upstreamB, revisionB := restB, ""
+	if i := strings.LastIndex(restB, "-"); i >= 0 {
+		upstreamB, revisionB = restB[:i], restB[i+1:]
+	}
+
+	if c := verrevcmp(upstreamA, upstreamB); c != 0 {
+		return c
+	}
+	return verrevcmp(revisionA, revisionB)
+}
+
+// debianOrder gives the sort weight of a character in the non-digit parts of a Debian version.
+func debianOrder(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return 0
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return int(c)
+	case c == '~':
+		return -1
+	case c == 0:
+		return 0
+	}
+	return int(c) + 256
+}
+
+func verrevcmp(a, b string) int {
+	i, j := 0, 0
+	at := func(s string, k int) byte {
+		if k < len(s) {
+			return s[k]
+		}
+		return 0
+	}
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	for i < len(a) || j < len(b) {
+		firstDiff := 0
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := debianOrder(at(a, i)), debianOrder(at(b, j))
+			if ac != bc {
+				return sign(ac - bc)
+			}
+			i++
+			j++
+		}
+		for at(a, i) == '0' {
+			i++
+		}
+		for at(b, j) == '0' {
+			j++
+		}
+		for isDigit(at(a, i)) && isDigit(at(b, j)) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if isDigit(at(a, i)) {
+			return 1
+		}
+		if isDigit(at(b, j)) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+// RPM versions ([epoch:]version[-release]), following rpmvercmp. Also used as the fallback
+// for versions that don't follow their ecosystem's format.
+
+func compareRPM(a, b string) int {
+	epochA, restA := splitEpoch(a)
+	epochB, restB := splitEpoch(b)
+	if c := sign(epochA - epochB); c != 0 {
+		return c
+	}
+	return rpmvercmp(restA, restB)
+}
+
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	isAlnum := func(c byte) bool {
+		return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isAlnum(a[i]) && a[i] != '~' && a[i] != '^' {
+			i++
+		}
+		for j < len(b) && !isAlnum(b[j]) && b[j] != '~' && b[j] != '^' {
+			j++
+		}
+
+		// A tilde sorts before everything, even the end of the version
+		tildeA, tildeB := i < len(a) && a[i] == '~', j < len(b) && b[j] == '~'
+		if tildeA || tildeB {
+			if !tildeA {
+				return 1
+			}
+			if !tildeB {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+
+		// A caret sorts after the end of the version but before anything else
+		caretA, caretB := i < len(a) && a[i] == '^', j < len(b) && b[j] == '^'
+		if caretA || caretB {
+			if i >= len(a) {
+				return -1
+			}
+			if j >= len(b) {
+				return 1
+			}
+			if !caretA {
+				return 1
+			}
+			if !caretB {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+
+		if i >= len(a) || j >= len(b) {
+			break
+		}
+
+		startA, startB := i, j
+		numeric := isDigit(a[i])
+		if numeric {
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+		} else {
+			for i < len(a) && isAlnum(a[i]) && !isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isAlnum(b[j]) && !isDigit(b[j]) {
+				j++
+			}
+		}
+
+		segA, segB := a[startA:i], b[startB:j]
+		if segB == "" {
+			// Segments of different types: numeric is newer
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		var c int
+		if numeric {
+			c = compareNumeric(segA, segB)
+		} else {
+			c = strings.Compare(segA, segB)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case i >= len(a) && j >= len(b):
+		return 0
+	case i >= len(a):
+		return -1
+	}
+	return 1
+}
+
+// Alpine versions (numbers[letter][_suffix...][~hash][-rN]), following apk-tools.
+
+var apkPattern = regexp.MustCompile(`^(\d+(?:\.\d+)*)([a-z]?)((?:_(?:alpha|beta|pre|rc|cvs|svn|git|hg|p)\d*)*)(?:~[0-9a-f]+)?(?:-r(\d+))?$`)
+
+var apkSuffixPattern = regexp.MustCompile(`_(alpha|beta|pre|rc|cvs|svn|git|hg|p)(\d*)`)
+
+// apkSuffixes orders the suffixes: pre-releases sort before the plain version, the rest after it.
+var apkSuffixes = map[string]int{
+	"alpha": -4,
+	"beta":  -3,
+	"pre":   -2,
+	"rc":    -1,
+	"cvs":   1,
+	"svn":   2,
+	"git":   3,
+	"hg":    4,
+	"p":     5,
+}
+
+type apkSuffix struct {
+	rank   int
+	number string
+}
+
+type apkVersion struct {
+	numbers  []string
+	letter   string
+	suffixes []apkSuffix
+	release  string
+}
+
+func parseAPK(s string) (apkVersion, bool) {
+	m := apkPattern.FindStringSubmatch(s)
+	if m == nil {
+		return apkVersion{}, false
+	}
+	v := apkVersion{numbers: strings.Split(m[1], "."), letter: m[2], release: m[4]}
+	for _, suffix := range apkSuffixPattern.FindAllStringSubmatch(m[3], -1) {
+		v.suffixes = append(v.suffixes, apkSuffix{rank: apkSuffixes[suffix[1]], number: suffix[2]})
+	}
+	return v, true
+}
+
+func compareAPK(a, b string) int {
+	va, okA := parseAPK(a)
+	vb, okB := parseAPK(b)
+	if !okA || !okB {
+		return compareRPM(a, b)
+	}
+
+	for i := 0; i < len(va.numbers) && i < len(vb.numbers); i++ {
+		pa, pb := va.numbers[i], vb.numbers[i]
+		var c int
+		if i > 0 && (strings.HasPrefix(pa, "0") || strings.HasPrefix(pb, "0")) {
+			// Like apk, a later part with a leading zero compares as a decimal fraction
+			c = strings.Compare(pa, pb)
+		} else {
+			c = compareNumeric(pa, pb)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	// Unlike semver, 1.0 is newer than 1
+	if c := sign(len(va.numbers) - len(vb.numbers)); c != 0 {
+		return c
+	}
+	if c := strings.Compare(va.letter, vb.letter); c != 0 {
+		return c
+	}
+
+	for i := 0; i < len(va.suffixes) || i < len(vb.suffixes); i++ {
+		// A missing suffix is the plain version, after pre-releases and before the rest
+		var sa, sb apkSuffix
+		if i < len(va.suffixes) {
+			sa = va.suffixes[i]
+		}
+		if i < len(vb.suffixes) {
+			sb = vb.suffixes[i]
+		}
+		if c := sign(sa.rank - sb.rank); c != 0 {
+			return c
+		}
+		if c := compareNumeric(sa.number, sb.number); c != 0 {
+			return c
+		}
+	}
+	return compareNumeric(va.release, vb.release)
+}
@@ -0,0 +1,127 @@
+package ecosystem
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		eco  Ecosystem
+		a, b string
+		want int
+	}{
+		// Semantic versioning
+		{Npm, "1.2.3", "1.2.10", -1},
+		{Npm, "v1.2.3", "1.2.3", 0},
+		{Npm, "1.2", "1.2.0", 0},
+		{Npm, "1.0.0-alpha", "1.0.0", -1},
+		{Npm, "1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{Npm, "1.0.0-rc.1", "1.0.0-beta.11", 1},
+		{Npm, "1.0.0+build.5", "1.0.0", 0},
+		{Go, "v0.0.0-20230101000000-abcdef", "v0.0.0-20240101000000-abcdef", -1},
+		{Cargo, "0.10.0", "0.9.9", 1},
+		{Composer, "2.4.1", "2.4.1", 0},
+		{NuGet, "4.3.0.1", "4.3.0", 1},
+		{NuGet, "1.0.0-RC1", "1.0.0-rc1", 0},
+
+		// PEP 440
+		{PyPI, "1.0", "1.0.0", 0},
+		{PyPI, "1.0.dev1", "1.0a1", -1},
+		{PyPI, "1.0a1", "1.0b1", -1},
+		{PyPI, "1.0rc1", "1.0", -1},
+		{PyPI, "1.0", "1.0.post1", -1},
+		{PyPI, "1.0.post1.dev1", "1.0.post1", -1},
+		{PyPI, "1!0.5", "2.0", 1},
+		{PyPI, "2.31.0", "2.4.0", 1},
+
+		// Maven
+		{Maven, "2.14.1", "2.15.0", -1},
+		{Maven, "1.0", "1.0.0-final", 0},
+		{Maven, "1.0-alpha-1", "1.0-beta-1", -1},
+		{Maven, "1.0-rc1", "1.0", -1},
+		{Maven, "1.0-SNAPSHOT", "1.0", -1},
+		{Maven, "1.0-sp1", "1.0", 1},
+		{Maven, "2.9.10.8", "2.9.10", 1},
+
+		// Debian
+		{Debian, "1.1.1n-0+deb11u3", "1.1.1n-0+deb11u4", -1},
+		{Debian, "1:1.0-1", "2.0-1", 1},
+		{Debian, "1.0~rc1-1", "1.0-1", -1},
+		{Debian, "2.36-9+deb12u4", "2.36-9+deb12u4", 0},
+		{Debian, "1.0-1ubuntu1", "1.0-1", 1},
+
+		// RPM
+		{RPM, "1.1.1k-7.el8_6", "1.1.1k-9.el8_7", -1},
+		{RPM, "1:1.0-1", "2.0-1", 1},
+		{RPM, "1.0~rc1", "1.0", -1},
+		{RPM, "1.0^git1", "1.0", 1},
+		{RPM, "2.28-225.el9", "2.28-225.el9", 0},
+
+		// Alpine
+		{Alpine, "1.2.3_rc1", "1.2.3", -1},
+		{Alpine, "1.2.3_alpha", "1.2.3_beta", -1},
+		{Alpine, "1.2.3_p1", "1.2.3", 1},
+		{Alpine, "1.2.3_git20240101", "1.2.3_p1", -1},
+		{Alpine, "1.2.3-r0", "1.2.3-r1", -1},
+		{Alpine, "3.0.11-r0", "3.0.12-r0", -1},
+		{Alpine, "1.1.1t-r2", "1.1.1u-r0", -1},
+		{Alpine, "1.0", "1", 1},
+		{Alpine, "1.02", "1.1", -1},
+		{Alpine, "2.40.1-r0", "2.40.1-r0", 0},
+
+		// Unknown ecosystems fall back on semver and rpm ordering
+		{Unknown, "1.20.0", "1.20.1", -1},
+		{Unknown, "2023.1", "2022.12", 1},
+	}
+	for _, tt := range tests {
+		if got := Compare(tt.eco, tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%q, %q, %q) = %d, want %d", tt.eco, tt.a, tt.b, got, tt.want)
+		}
+		if got := Compare(tt.eco, tt.b, tt.a); got != -tt.want {
+			t.Errorf("Compare(%q, %q, %q) = %d, want %d", tt.eco, tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestIsFixed(t *testing.T) {
+	tests := []struct {
+		eco              Ecosystem
+		installed, fixed string
+		want             bool
+	}{
+		{Maven, "2.15.0", "2.15.0", true},
+		{Maven, "2.14.1", "2.15.0", false},
+		{Alpine, "1.2.3_rc1", "1.2.3", false},
+		{Alpine, "1.2.3-r1", "1.2.3-r0", true},
+		{Unknown, "2.0", "1.0", false},
+		{Npm, "", "1.0", false},
+		{Npm, "2.0.0", "1.9.1, 2.1.0", false},
+	}
+	for _, tt := range tests {
+		if got := IsFixed(tt.eco, tt.installed, tt.fixed); got != tt.want {
+			t.Errorf("IsFixed(%q, %q, %q) = %v, want %v", tt.eco, tt.installed, tt.fixed, got, tt.want)
+		}
+	}
+}
+
+func TestKnownLibraryEcosystem(t *testing.T) {
+	tests := []struct {
+		name, path     string
+		detected, want Ecosystem
+	}{
+		{"lodash", "/srv/web/node_modules/lodash/package.json", Npm, Npm},
+		{"log4j-core", "/opt/app/lib/log4j-core-2.14.1.jar", Maven, Maven},
+		{"Newtonsoft.Json", "/app/bin/Newtonsoft.Json.dll", NuGet, Unknown},
+		{"requests", "/srv/app/requirements.txt", PyPI, Unknown},
+		{"log4j-core", "/srv/requirements/lib/log4j-core-2.14.1.jar", Maven, Maven},
+		{"org.apache.logging.log4j:log4j-core", "", Maven, Unknown},
+		{"github.com/gin-gonic/gin", "", Go, Unknown},
+		{"zlib", "/usr/lib/libz.so", Unknown, Unknown},
+	}
+	for _, tt := range tests {
+		if got := DetectLibrary(tt.name, tt.path); got != tt.detected {
+			t.Errorf("DetectLibrary(%q, %q) = %q, want %q", tt.name, tt.path, got, tt.detected)
+		}
+		if got := KnownLibraryEcosystem(tt.name, tt.path); got != tt.want {
+			t.Errorf("KnownLibraryEcosystem(%q, %q) = %q, want %q", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
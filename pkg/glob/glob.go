@@ -0,0 +1,69 @@
+package glob
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	globCache   = make(map[string]*regexp.Regexp)
+	globCacheMu sync.Mutex
+)
+
+// Match reports whether s matches pattern, case-insensitively. "?" matches one character
+// and "*" any run of characters; for paths they stop at a separator and "**" crosses
+// directories. Backslashes in Windows paths are treated as forward slashes.
+func Match(pattern, s string, isPath bool) bool {
+	if isPath {
+		pattern = strings.ReplaceAll(pattern, "\\", "/")
+		s = strings.ReplaceAll(s, "\\", "/")
+	}
+	key := pattern
+	if isPath {
+		key = "path:" + pattern
+	}
+
+	globCacheMu.Lock()
+	re, ok := globCache[key]
+	if !ok {
+		re = compileGlob(pattern, isPath)
+		globCache[key] = re
+	}
+	globCacheMu.Unlock()
+
+	return re.MatchString(s)
+}
+
+func compileGlob(pattern string, isPath bool) *regexp.Regexp {
+	star, single := ".*", "."
+	if isPath {
+		star, single = "[^/]*", "[^/]"
+	}
+
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if isPath && i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				// "**/" also matches no directory at all.
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+				continue
+			}
+			b.WriteString(star)
+		case '?':
+			b.WriteString(single)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,370 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+)
+
+// FileName is the name of the history database in the state directory.
+const FileName = "history.db"
+
+var (
+	runsBucket        = []byte("runs")
+	runFindingsBucket = []byte("runFindings")
+	findingsBucket    = []byte("findings")
+)
+
+// ErrNoRuns is returned when a report needs more runs than the history holds.
+var ErrNoRuns = errors.New("not enough runs recorded")
+
+// Run describes one recorded wiz-scan run.
+type Run struct {
+	ID         uint64    `json:"id"`
+	Time       time.Time `json:"time"`
+	HostName   string    `json:"hostName"`
+	ProviderID string    `json:"providerId"`
+	Findings   int       `json:"findings"`
+}
+
+// Finding is the normalized form of a scanned vulnerability kept for every run.
+type Finding struct {
+	FindingID    string `json:"findingId"`
+	Type         string `json:"type"`
+	CVE          string `json:"cve"`
+	Package      string `json:"package"`
+	Version      string `json:"version"`
+	FixedVersion string `json:"fixedVersion"`
+	Path         string `json:"path"`
+	Severity     string `json:"severity"`
+}
+
+// Seen records when a finding was first and last found, across all runs ever recorded.
+type Seen struct {
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	FirstRun  uint64    `json:"firstRun"`
+	LastRun   uint64    `json:"lastRun"`
+}
+
+// Store is the local history database.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the history database in dir.
+func Open(dir string) (*Store, error) {
+	db, err := bolt.Open(filepath.Join(dir, FileName), 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{runsBucket, runFindingsBucket, findingsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history database: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record stores the findings of a run from its comparison verdicts and updates their
+// first and last seen dates. Only vulnerabilities on the host count as findings, so those
+// already fixed, suppressed or dropped by policy are left out. Runs beyond the newest keep
+// are removed, along with the seen dates of findings none of the remaining runs has.
+func (s *Store) Record(run Run, verdicts []vulnerability.Verdict, keep int) (Run, error) {
+	findings := make(map[string]Finding, len(verdicts))
+	for _, v := range verdicts {
+		if !isFinding(&v) {
+			continue
+		}
+		if _, ok := findings[v.FindingID]; ok {
+			continue
+		}
+		findings[v.FindingID] = Finding{
+			FindingID:    v.FindingID,
+			Type:         v.Type,
+			CVE:          v.CVE,
+			Package:      v.Package,
+			Version:      v.Version,
+			FixedVersion: v.FixedVersion,
+			Path:         v.Path,
+			Severity:     v.Severity,
+		}
+	}
+	run.Findings = len(findings)
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		runs := tx.Bucket(runsBucket)
+		id, err := runs.NextSequence()
+		if err != nil {
+			return err
+		}
+		run.ID = id
+		if err := putJSON(runs, itob(id), run); err != nil {
+			return err
+		}
+
+		bucket, err := tx.Bucket(runFindingsBucket).CreateBucket(itob(id))
+		if err != nil {
+			return err
+		}
+		seenBucket := tx.Bucket(findingsBucket)
+		for key, finding := range findings {
+			if err := putJSON(bucket, []byte(key), finding); err != nil {
+				return err
+			}
+			seen := Seen{FirstSeen: run.Time, FirstRun: id}
+			if err := getJSON(seenBucket, []byte(key), &seen); err != nil {
+				return err
+			}
+			seen.LastSeen, seen.LastRun = run.Time, id
+			if err := putJSON(seenBucket, []byte(key), seen); err != nil {
+				return err
+			}
+		}
+		return prune(tx, keep)
+	})
+	if err != nil {
+		return run, fmt.Errorf("failed to record run: %w", err)
+	}
+	return run, nil
+}
+
+// isFinding reports whether a verdict is a vulnerability present and reported on the host.
+func isFinding(v *vulnerability.Verdict) bool {
+	return v.OnHost() && v.Action != vulnerability.ActionSuppress && v.Action != vulnerability.ActionDrop
+}
+
+// prune removes all but the newest keep runs, and the seen dates of findings last seen
+// before the oldest remaining run; keep of zero or less keeps every run.
+func prune(tx *bolt.Tx, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	runs := tx.Bucket(runsBucket)
+	var ids [][]byte
+	c := runs.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		ids = append(ids, append([]byte(nil), k...))
+	}
+	if len(ids) <= keep {
+		return nil
+	}
+	for _, k := range ids[:len(ids)-keep] {
+		if err := runs.Delete(k); err != nil {
+			return err
+		}
+		if err := tx.Bucket(runFindingsBucket).DeleteBucket(k); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return err
+		}
+	}
+
+	oldest := binary.BigEndian.Uint64(ids[len(ids)-keep])
+	seenBucket := tx.Bucket(findingsBucket)
+	var stale [][]byte
+	err := seenBucket.ForEach(func(k, v []byte) error {
+		var seen Seen
+		if err := json.Unmarshal(v, &seen); err != nil {
+			return err
+		}
+		if seen.LastRun < oldest {
+			stale = append(stale, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range stale {
+		if err := seenBucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Runs returns the recorded runs, oldest first.
+func (s *Store) Runs() ([]Run, error) {
+	var runs []Run
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).ForEach(func(_, v []byte) error {
+			var run Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return err
+			}
+			runs = append(runs, run)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runs: %w", err)
+	}
+	return runs, nil
+}
+
+// Change is a finding in a Diff together with its first and last seen dates.
+type Change struct {
+	Finding
+	Seen
+}
+
+// Diff lists the findings that are new in run to, fixed since run from, and present in both.
+type Diff struct {
+	From       Run      `json:"from"`
+	To         Run      `json:"to"`
+	New        []Change `json:"new"`
+	Fixed      []Change `json:"fixed"`
+	Persisting []Change `json:"persisting"`
+}
+
+// Diff compares two runs. Zero IDs select the two most recent runs.
+func (s *Store) Diff(from, to uint64) (*Diff, error) {
+	runs, err := s.Runs()
+	if err != nil {
+		return nil, err
+	}
+	if to == 0 {
+		if len(runs) == 0 {
+			return nil, ErrNoRuns
+		}
+		to = runs[len(runs)-1].ID
+	}
+	if from == 0 {
+		for i := len(runs) - 1; i >= 0; i-- {
+			if runs[i].ID < to {
+				from = runs[i].ID
+				break
+			}
+		}
+		if from == 0 {
+			return nil, ErrNoRuns
+		}
+	}
+
+	diff := &Diff{New: []Change{}, Fixed: []Change{}, Persisting: []Change{}}
+	err = s.db.View(func(tx *bolt.Tx) error {
+		if err := getRun(tx, from, &diff.From); err != nil {
+			return err
+		}
+		if err := getRun(tx, to, &diff.To); err != nil {
+			return err
+		}
+		before, err := runFindings(tx, from)
+		if err != nil {
+			return err
+		}
+		after, err := runFindings(tx, to)
+		if err != nil {
+			return err
+		}
+
+		seenBucket := tx.Bucket(findingsBucket)
+		change := func(f Finding) (Change, error) {
+			c := Change{Finding: f}
+			return c, getJSON(seenBucket, []byte(f.FindingID), &c.Seen)
+		}
+		for key, f := range after {
+			c, err := change(f)
+			if err != nil {
+				return err
+			}
+			if _, ok := before[key]; ok {
+				diff.Persisting = append(diff.Persisting, c)
+			} else {
+				diff.New = append(diff.New, c)
+			}
+		}
+		for key, f := range before {
+			if _, ok := after[key]; ok {
+				continue
+			}
+			c, err := change(f)
+			if err != nil {
+				return err
+			}
+			diff.Fixed = append(diff.Fixed, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare runs %d and %d: %w", from, to, err)
+	}
+
+	for _, changes := range [][]Change{diff.New, diff.Fixed, diff.Persisting} {
+		sort.Slice(changes, func(i, j int) bool {
+			if changes[i].CVE != changes[j].CVE {
+				return changes[i].CVE < changes[j].CVE
+			}
+			return changes[i].FindingID < changes[j].FindingID
+		})
+	}
+	return diff, nil
+}
+
+func getRun(tx *bolt.Tx, id uint64, run *Run) error {
+	data := tx.Bucket(runsBucket).Get(itob(id))
+	if data == nil {
+		return fmt.Errorf("run %d is not recorded", id)
+	}
+	return json.Unmarshal(data, run)
+}
+
+func runFindings(tx *bolt.Tx, id uint64) (map[string]Finding, error) {
+	bucket := tx.Bucket(runFindingsBucket).Bucket(itob(id))
+	if bucket == nil {
+		return nil, fmt.Errorf("findings of run %d are not recorded", id)
+	}
+	findings := make(map[string]Finding)
+	err := bucket.ForEach(func(k, v []byte) error {
+		var f Finding
+		if err := json.Unmarshal(v, &f); err != nil {
+			return err
+		}
+		findings[string(k)] = f
+		return nil
+	})
+	return findings, err
+}
+
+// getJSON decodes the value of key into v, leaving v untouched when the key is missing.
+func getJSON(bucket *bolt.Bucket, key []byte, v interface{}) error {
+	data := bucket.Get(key)
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+func putJSON(bucket *bolt.Bucket, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+// itob encodes a run ID so keys sort in run order.
+func itob(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
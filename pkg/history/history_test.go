@@ -0,0 +1,90 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+)
+
+func TestRecordOnlyKeepsFindingsOnTheHost(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	verdicts := []vulnerability.Verdict{
+		{Action: vulnerability.ActionAdd, FindingID: "WIZCLI-1"},
+		{Action: vulnerability.ActionKeep, FindingID: "WIZCLI-2"},
+		{Action: vulnerability.ActionIgnore, FindingID: "WIZCLI-3", WizFindingID: "disk-3"},
+		{Action: vulnerability.ActionIgnore, FindingID: "WIZCLI-4", AlreadyFixed: true},
+		{Action: vulnerability.ActionSuppress, FindingID: "WIZCLI-5"},
+		{Action: vulnerability.ActionDrop, FindingID: "WIZCLI-6"},
+	}
+	run, err := store.Record(Run{Time: time.Now()}, verdicts, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Findings != 3 {
+		t.Errorf("recorded %d findings, want 3", run.Findings)
+	}
+}
+
+func TestRecordPrunesSeenDates(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	runs := [][]string{
+		{"WIZCLI-1", "WIZCLI-2"},
+		{"WIZCLI-2"},
+		{"WIZCLI-2", "WIZCLI-3"},
+	}
+	for i, ids := range runs {
+		var verdicts []vulnerability.Verdict
+		for _, id := range ids {
+			verdicts = append(verdicts, vulnerability.Verdict{Action: vulnerability.ActionAdd, FindingID: id})
+		}
+		if _, err := store.Record(Run{Time: start.AddDate(0, 0, i)}, verdicts, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recorded, err := store.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("kept %d runs, want 2", len(recorded))
+	}
+
+	seen := make(map[string]Seen)
+	err = store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(findingsBucket).ForEach(func(k, _ []byte) error {
+			var s Seen
+			if err := getJSON(tx.Bucket(findingsBucket), k, &s); err != nil {
+				return err
+			}
+			seen[string(k)] = s
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := seen["WIZCLI-1"]; ok {
+		t.Error("the seen dates of a finding no kept run has were kept")
+	}
+	if !seen["WIZCLI-2"].FirstSeen.Equal(start) {
+		t.Errorf("WIZCLI-2 first seen %s, want the first run", seen["WIZCLI-2"].FirstSeen)
+	}
+	if _, ok := seen["WIZCLI-3"]; !ok {
+		t.Error("WIZCLI-3 is missing")
+	}
+}
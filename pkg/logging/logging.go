@@ -0,0 +1,125 @@
+// Package logging configures the logrus logger every wiz-scan package writes to.
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Options select the level, format and destination of the log.
+type Options struct {
+	Level string
+	// Format is "text" or "json"
+	Format string
+	// File is written instead of stderr when set, and rotated by size and age
+	File       string
+	MaxSizeMB  int
+	MaxAgeDays int
+	MaxBackups int
+}
+
+var (
+	mu    sync.RWMutex
+	runID string
+	phase string
+	file  *RotatingFile
+)
+
+func init() {
+	StartRun()
+}
+
+// Configure applies opts to logger and adds the run and phase fields to every entry.
+func Configure(logger *logrus.Logger, opts Options) error {
+	level, err := logrus.ParseLevel(opts.Level)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q", opts.Level)
+	}
+
+	var formatter logrus.Formatter
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		formatter = &logrus.TextFormatter{FullTimestamp: true}
+	case "json":
+		formatter = &logrus.JSONFormatter{}
+	default:
+		return fmt.Errorf("unsupported log format %q (supported: text, json)", opts.Format)
+	}
+
+	var out io.Writer = os.Stderr
+	var rotating *RotatingFile
+	if opts.File != "" {
+		rotating, err = OpenRotatingFile(opts.File, opts.MaxSizeMB, opts.MaxAgeDays, opts.MaxBackups)
+		if err != nil {
+			return err
+		}
+		out = rotating
+	}
+
+	mu.Lock()
+	previous := file
+	file = rotating
+	mu.Unlock()
+
+	logger.SetLevel(level)
+	logger.SetFormatter(formatter)
+	logger.SetOutput(out)
+	logger.ReplaceHooks(make(logrus.LevelHooks))
+	logger.AddHook(runHook{})
+
+	if previous != nil {
+		previous.Close()
+	}
+	return nil
+}
+
+// StartRun gives the entries that follow a new run ID and returns it.
+func StartRun() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	runID = hex.EncodeToString(id)
+	phase = ""
+	return runID
+}
+
+// RunID returns the ID of the current run.
+func RunID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return runID
+}
+
+// SetPhase names the step of the run, such as "scan" or "publish", that entries belong to.
+func SetPhase(p string) {
+	mu.Lock()
+	defer mu.Unlock()
+	phase = p
+}
+
+// runHook adds the run ID and phase to entries.
+type runHook struct{}
+
+func (runHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (runHook) Fire(entry *logrus.Entry) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	entry.Data["run"] = runID
+	if phase != "" {
+		entry.Data["phase"] = phase
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jtb75/wiz-scan/pkg/glob"
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+)
+
+// Rule actions.
+const (
+	// ActionDrop excludes matching findings from the upload
+	ActionDrop = "drop"
+	// ActionDowngrade lowers the severity of matching findings to the rule's severity
+	ActionDowngrade = "downgrade"
+	// ActionFlag counts matching vulnerabilities as gate violations, whether uploaded or not
+	ActionFlag = "flag"
+)
+
+// severityRank orders the severities used by Wiz findings.
+var severityRank = map[string]int{
+	"None":     0,
+	"Low":      1,
+	"Medium":   2,
+	"High":     3,
+	"Critical": 4,
+}
+
+// Policy decides which findings are published and whether the run fails. Flag rules
+// are evaluated over every vulnerability found on the host as scanned, including those
+// Wiz already reports and those drop rules exclude from the upload. Drop and downgrade
+// rules only change what is uploaded and are evaluated in order: a drop rule ends
+// evaluation for the finding, a downgrade rule lets later rules see the new severity.
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+	Gate  Gate   `yaml:"gate"`
+}
+
+// Rule applies Action to every finding that satisfies all of its match conditions.
+type Rule struct {
+	Name     string `yaml:"name"`
+	Match    Match  `yaml:"match"`
+	Action   string `yaml:"action"`
+	Severity string `yaml:"severity"`
+}
+
+// Match lists the conditions a finding must satisfy. Unset conditions always match and
+// list conditions match when any entry does.
+type Match struct {
+	Severities        []string `yaml:"severities"`
+	MinSeverity       string   `yaml:"minSeverity"`
+	MaxSeverity       string   `yaml:"maxSeverity"`
+	KEV               *bool    `yaml:"kev"`
+	HasExploit        *bool    `yaml:"hasExploit"`
+	MinEpssPercentile *float64 `yaml:"minEpssPercentile"`
+	FixAvailable      *bool    `yaml:"fixAvailable"`
+	CVEs              []string `yaml:"cves"`
+	Packages          []string `yaml:"packages"`
+	Paths             []string `yaml:"paths"`
+}
+
+// Gate fails the run with ExitCode when more than MaxViolations findings were flagged.
+type Gate struct {
+	ExitCode      int `yaml:"exitCode"`
+	MaxViolations int `yaml:"maxViolations"`
+}
+
+// Violation is a vulnerability flag rules matched, once per finding ID.
+type Violation struct {
+	// Rule names the flag rules that matched, comma separated
+	Rule      string
+	FindingID string
+	CVE       string
+	Package   string
+	Path      string
+	Severity  string
+}
+
+// Result summarises what Apply did to the findings.
+type Result struct {
+	Dropped    int
+	Downgraded int
+	Violations []Violation
+}
+
+// Load reads and validates a YAML policy file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var p Policy
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		rule.Action = strings.ToLower(rule.Action)
+		switch rule.Action {
+		case ActionDrop, ActionFlag:
+		case ActionDowngrade:
+			severity, ok := normalizeSeverity(rule.Severity)
+			if !ok {
+				return fmt.Errorf("%s: downgrade needs a severity (None, Low, Medium, High, Critical), got %q", rule.Name, rule.Severity)
+			}
+			rule.Severity = severity
+		default:
+			return fmt.Errorf("%s: unknown action %q (supported: drop, downgrade, flag)", rule.Name, rule.Action)
+		}
+		if err := rule.Match.validate(); err != nil {
+			return fmt.Errorf("%s: %w", rule.Name, err)
+		}
+	}
+	if p.Gate.ExitCode < 0 || p.Gate.ExitCode > 125 {
+		return fmt.Errorf("gate exit code must be between 0 and 125, got %d", p.Gate.ExitCode)
+	}
+	if p.Gate.ExitCode == 0 {
+		p.Gate.ExitCode = 1
+	}
+	return nil
+}
+
+func (m *Match) validate() error {
+	for i, s := range m.Severities {
+		severity, ok := normalizeSeverity(s)
+		if !ok {
+			return fmt.Errorf("unknown severity %q", s)
+		}
+		m.Severities[i] = severity
+	}
+	for _, s := range []*string{&m.MinSeverity, &m.MaxSeverity} {
+		if *s == "" {
+			continue
+		}
+		severity, ok := normalizeSeverity(*s)
+		if !ok {
+			return fmt.Errorf("unknown severity %q", *s)
+		}
+		*s = severity
+	}
+	if m.MinEpssPercentile != nil && (*m.MinEpssPercentile < 0 || *m.MinEpssPercentile > 1) {
+		return fmt.Errorf("minEpssPercentile must be between 0 and 1, got %v", *m.MinEpssPercentile)
+	}
+	return nil
+}
+
+// Apply runs the policy over the verdicts and the findings of asset, removing dropped
+// findings and updating the matching verdicts so the report shows what the policy changed.
+// Suppressed vulnerabilities are accepted risks and never violations.
+func (p *Policy) Apply(asset *vulnerability.Asset, verdicts []vulnerability.Verdict) Result {
+	var result Result
+
+	// The gate sees the vulnerabilities before drop and downgrade rules change them
+	flagged := make(map[*vulnerability.Verdict][]string)
+	byFindingID := make(map[string]int)
+	for i := range verdicts {
+		v := &verdicts[i]
+		if !v.OnHost() || v.Action == vulnerability.ActionSuppress {
+			continue
+		}
+		for _, rule := range p.Rules {
+			if rule.Action != ActionFlag || !rule.Match.matches(v) {
+				continue
+			}
+			flagged[v] = append(flagged[v], rule.Name)
+			if j, ok := byFindingID[v.FindingID]; ok {
+				// The same finding scanned twice is one violation
+				if rules := strings.Split(result.Violations[j].Rule, ", "); !contains(rules, rule.Name) {
+					result.Violations[j].Rule += ", " + rule.Name
+				}
+				continue
+			}
+			byFindingID[v.FindingID] = len(result.Violations)
+			result.Violations = append(result.Violations, Violation{
+				Rule:      rule.Name,
+				FindingID: v.FindingID,
+				CVE:       v.CVE,
+				Package:   v.Package,
+				Path:      v.Path,
+				Severity:  v.Severity,
+			})
+		}
+	}
+
+	// upload records a verdict for every finding in the same order, so queue the
+	// uploaded verdicts per finding ID to pair them up again.
+	pending := make(map[string][]*vulnerability.Verdict)
+	for i := range verdicts {
+		v := &verdicts[i]
+		if v.Action == vulnerability.ActionAdd || v.Action == vulnerability.ActionKeep {
+			pending[v.FindingID] = append(pending[v.FindingID], v)
+		}
+	}
+
+	kept := asset.VulnerabilityFindings[:0]
+	for _, finding := range asset.VulnerabilityFindings {
+		var verdict *vulnerability.Verdict
+		if queue := pending[finding.Id]; len(queue) > 0 {
+			verdict = queue[0]
+			pending[finding.Id] = queue[1:]
+		} else {
+			verdict = &vulnerability.Verdict{
+				CVE:          finding.Name,
+				Package:      finding.DetailedName,
+				FixedVersion: finding.FixedVersion,
+				Path:         finding.Path,
+				Severity:     finding.Severity,
+				FindingID:    finding.Id,
+				Risk:         finding.RiskAttributes,
+			}
+		}
+		if p.evaluate(&finding, verdict, &result) {
+			kept = append(kept, finding)
+		}
+	}
+	asset.VulnerabilityFindings = kept
+
+	for v, rules := range flagged {
+		for _, rule := range rules {
+			v.Reason += fmt.Sprintf("; flagged by policy rule %q", rule)
+		}
+	}
+	return result
+}
+
+// evaluate applies the drop and downgrade rules to a finding and reports whether it should
+// still be uploaded.
+func (p *Policy) evaluate(finding *vulnerability.VulnerabilityFinding, verdict *vulnerability.Verdict, result *Result) bool {
+	for _, rule := range p.Rules {
+		if rule.Action == ActionFlag || !rule.Match.matches(verdict) {
+			continue
+		}
+		switch rule.Action {
+		case ActionDrop:
+			verdict.Action = vulnerability.ActionDrop
+			verdict.Reason = fmt.Sprintf("dropped by policy rule %q", rule.Name)
+			result.Dropped++
+			return false
+		case ActionDowngrade:
+			if severityRank[rule.Severity] >= severityRank[verdict.Severity] {
+				continue
+			}
+			verdict.Reason += fmt.Sprintf("; severity downgraded from %s by policy rule %q", verdict.Severity, rule.Name)
+			finding.Severity = rule.Severity
+			verdict.Severity = rule.Severity
+			result.Downgraded++
+		}
+	}
+	return true
+}
+
+func (m *Match) matches(v *vulnerability.Verdict) bool {
+	rank := severityRank[v.Severity]
+	if len(m.Severities) > 0 && !contains(m.Severities, v.Severity) {
+		return false
+	}
+	if m.MinSeverity != "" && rank < severityRank[m.MinSeverity] {
+		return false
+	}
+	if m.MaxSeverity != "" && rank > severityRank[m.MaxSeverity] {
+		return false
+	}
+	if m.KEV != nil && *m.KEV != v.Risk.HasCisaKevExploit {
+		return false
+	}
+	if m.HasExploit != nil && *m.HasExploit != v.Risk.HasExploit {
+		return false
+	}
+	if m.MinEpssPercentile != nil && (v.Risk.EpssPercentile == nil || *v.Risk.EpssPercentile < *m.MinEpssPercentile) {
+		return false
+	}
+	if m.FixAvailable != nil && *m.FixAvailable != (v.FixedVersion != "") {
+		return false
+	}
+	if len(m.CVEs) > 0 && !matchAny(m.CVEs, v.CVE, false) {
+		return false
+	}
+	if len(m.Packages) > 0 && !matchAny(m.Packages, v.Package, false) {
+		return false
+	}
+	if len(m.Paths) > 0 && !matchAny(m.Paths, v.Path, true) {
+		return false
+	}
+	return true
+}
+
+func normalizeSeverity(s string) (string, bool) {
+	if s == "" {
+		return "", false
+	}
+	normalized := strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
+	_, ok := severityRank[normalized]
+	return normalized, ok
+}
+
+func contains(list []string, s string) bool {
+	for _, entry := range list {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, s string, isPath bool) bool {
+	for _, pattern := range patterns {
+		if glob.Match(pattern, s, isPath) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+)
+
+func TestApplyGatesEveryVulnerabilityOnTheHost(t *testing.T) {
+	yes := true
+	p := &Policy{
+		Rules: []Rule{
+			{Name: "fixtures", Match: Match{Paths: []string{"/opt/app/test/**"}}, Action: ActionDrop},
+			{Name: "downgrade", Match: Match{Packages: []string{"openssl"}}, Action: ActionDowngrade, Severity: "Low"},
+			{Name: "kev", Match: Match{KEV: &yes}, Action: ActionFlag},
+			{Name: "critical", Match: Match{MinSeverity: "Critical"}, Action: ActionFlag},
+		},
+	}
+	if err := p.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	kev := vulnerability.RiskAttributes{HasCisaKevExploit: true}
+	verdicts := []vulnerability.Verdict{
+		// Uploaded, flagged by both rules and scanned twice
+		{Action: vulnerability.ActionAdd, CVE: "CVE-1", Package: "log4j-core", Path: "/opt/app/lib/log4j-core.jar", Severity: "Critical", FindingID: "WIZCLI-1", Risk: kev},
+		{Action: vulnerability.ActionAdd, CVE: "CVE-1", Package: "log4j-core", Path: "/opt/app/lib/log4j-core.jar", Severity: "Critical", FindingID: "WIZCLI-1", Risk: kev},
+		// Already reported by Wiz, so not uploaded, but still on the host
+		{Action: vulnerability.ActionIgnore, CVE: "CVE-2", Package: "lodash", Severity: "High", FindingID: "WIZCLI-2", WizFindingID: "disk-2", Risk: kev},
+		// Dropped from the upload by a rule, but still on the host
+		{Action: vulnerability.ActionAdd, CVE: "CVE-3", Package: "junit", Path: "/opt/app/test/junit.jar", Severity: "Critical", FindingID: "WIZCLI-3"},
+		// Downgraded for the upload, gated on the scanned severity
+		{Action: vulnerability.ActionAdd, CVE: "CVE-4", Package: "openssl", Severity: "Critical", FindingID: "WIZCLI-4"},
+		// Not violations
+		{Action: vulnerability.ActionSuppress, CVE: "CVE-5", Package: "zlib", Severity: "Critical", FindingID: "WIZCLI-5", Risk: kev},
+		{Action: vulnerability.ActionIgnore, CVE: "CVE-6", Package: "curl", Severity: "Critical", FindingID: "WIZCLI-6", AlreadyFixed: true},
+		{Action: vulnerability.ActionAdd, CVE: "CVE-7", Package: "bash", Severity: "Medium", FindingID: "WIZCLI-7"},
+	}
+	asset := vulnerability.Asset{}
+	for _, v := range verdicts {
+		if v.Action == vulnerability.ActionAdd {
+			asset.VulnerabilityFindings = append(asset.VulnerabilityFindings, vulnerability.VulnerabilityFinding{
+				Id: v.FindingID, Name: v.CVE, DetailedName: v.Package, Severity: v.Severity, Path: v.Path, RiskAttributes: v.Risk,
+			})
+		}
+	}
+
+	result := p.Apply(&asset, verdicts)
+
+	want := map[string]string{
+		"WIZCLI-1": "kev, critical",
+		"WIZCLI-2": "kev",
+		"WIZCLI-3": "critical",
+		"WIZCLI-4": "critical",
+	}
+	if len(result.Violations) != len(want) {
+		t.Fatalf("got %d violations %+v, want %d", len(result.Violations), result.Violations, len(want))
+	}
+	for _, v := range result.Violations {
+		if want[v.FindingID] != v.Rule {
+			t.Errorf("%s flagged by %q, want %q", v.FindingID, v.Rule, want[v.FindingID])
+		}
+	}
+
+	if result.Dropped != 1 || result.Downgraded != 1 {
+		t.Errorf("dropped %d and downgraded %d, want 1 and 1", result.Dropped, result.Downgraded)
+	}
+	if verdicts[3].Action != vulnerability.ActionDrop {
+		t.Errorf("CVE-3 is %s, want Drop", verdicts[3].Action)
+	}
+	for _, f := range asset.VulnerabilityFindings {
+		if f.Id == "WIZCLI-3" {
+			t.Error("the dropped finding is still uploaded")
+		}
+		if f.Id == "WIZCLI-4" && f.Severity != "Low" {
+			t.Errorf("the downgraded finding is uploaded as %s", f.Severity)
+		}
+	}
+}
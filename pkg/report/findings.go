@@ -0,0 +1,173 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+)
+
+// Metadata describes the run a findings report belongs to.
+type Metadata struct {
+	HostName    string
+	ToolVersion string
+	Timestamp   time.Time
+}
+
+// FindingsWriter renders the findings of an upload payload in one report format.
+type FindingsWriter interface {
+	WriteFindings(w io.Writer, data vulnerability.IntegrationData, meta Metadata) error
+}
+
+// FindingsWriterFunc adapts a function to a FindingsWriter.
+type FindingsWriterFunc func(w io.Writer, data vulnerability.IntegrationData, meta Metadata) error
+
+func (f FindingsWriterFunc) WriteFindings(w io.Writer, data vulnerability.IntegrationData, meta Metadata) error {
+	return f(w, data, meta)
+}
+
+type findingsFormat struct {
+	writer     FindingsWriter
+	extensions []string
+}
+
+var findingsFormats = make(map[string]findingsFormat)
+
+// RegisterFindingsWriter makes a format available to WriteFindings. Files with one of the
+// extensions get the format when an output doesn't name one.
+func RegisterFindingsWriter(name string, writer FindingsWriter, extensions ...string) {
+	findingsFormats[strings.ToLower(name)] = findingsFormat{writer: writer, extensions: extensions}
+}
+
+func init() {
+	RegisterFindingsWriter("json", FindingsWriterFunc(writeFindingsJSON), ".json")
+	RegisterFindingsWriter("sarif", FindingsWriterFunc(writeFindingsSARIF), ".sarif")
+	RegisterFindingsWriter("csv", FindingsWriterFunc(writeFindingsCSV), ".csv")
+	RegisterFindingsWriter("junit", FindingsWriterFunc(writeFindingsJUnit), ".xml")
+	RegisterFindingsWriter("html", FindingsWriterFunc(writeFindingsHTML), ".html", ".htm")
+}
+
+// FindingsFormats lists the registered format names.
+func FindingsFormats() []string {
+	names := make([]string, 0, len(findingsFormats))
+	for name := range findingsFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteFindings renders data in the named format.
+func WriteFindings(w io.Writer, data vulnerability.IntegrationData, format string, meta Metadata) error {
+	f, ok := findingsFormats[strings.ToLower(format)]
+	if !ok {
+		return fmt.Errorf("unsupported findings format %q (supported: %s)", format, strings.Join(FindingsFormats(), ", "))
+	}
+	return f.writer.WriteFindings(w, data, meta)
+}
+
+// WriteFindingsOutputs writes data to every output of a comma separated list. An output is
+// "format=path", or a path whose extension names the format; "-" writes to stdout.
+func WriteFindingsOutputs(outputs string, data vulnerability.IntegrationData, meta Metadata) error {
+	for _, output := range strings.Split(outputs, ",") {
+		output = strings.TrimSpace(output)
+		if output == "" {
+			continue
+		}
+		format, path, ok := strings.Cut(output, "=")
+		if !ok {
+			path, format = output, formatForPath(output)
+			if format == "" {
+				return fmt.Errorf("can't tell the format of %s, write it as format=%s", output, output)
+			}
+		}
+		if err := writeFindingsFile(path, data, format, meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatForPath(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	for name, f := range findingsFormats {
+		for _, e := range f.extensions {
+			if e == ext {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func writeFindingsFile(path string, data vulnerability.IntegrationData, format string, meta Metadata) error {
+	if path == "-" || path == "" {
+		return WriteFindings(os.Stdout, data, format, meta)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create findings report: %w", err)
+	}
+	defer file.Close()
+	if err := WriteFindings(file, data, format, meta); err != nil {
+		return fmt.Errorf("failed to write %s findings report %s: %w", format, path, err)
+	}
+	return nil
+}
+
+// assetFinding is a finding together with the asset it belongs to.
+type assetFinding struct {
+	vulnerability.VulnerabilityFinding
+	Asset vulnerability.AssetIdentifier
+}
+
+// flatten lists the findings of every data source and asset.
+func flatten(data vulnerability.IntegrationData) []assetFinding {
+	var findings []assetFinding
+	for _, ds := range data.DataSources {
+		for _, asset := range ds.Assets {
+			for _, f := range asset.VulnerabilityFindings {
+				findings = append(findings, assetFinding{VulnerabilityFinding: f, Asset: asset.AssetIdentifier})
+			}
+		}
+	}
+	return findings
+}
+
+// writeFindingsJSON writes the payload exactly as it would be uploaded.
+func writeFindingsJSON(w io.Writer, data vulnerability.IntegrationData, _ Metadata) error {
+	return writeJSON(w, data)
+}
+
+func writeFindingsCSV(w io.Writer, data vulnerability.IntegrationData, _ Metadata) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "providerId", "cve", "severity", "package", "version", "fixedVersion", "path",
+		"detectionSource", "hasExploit", "hasCisaKevExploit", "epssPercentile", "link", "remediation"})
+	for _, f := range flatten(data) {
+		epss := ""
+		if f.EpssPercentile != nil {
+			epss = fmt.Sprint(*f.EpssPercentile)
+		}
+		cw.Write([]string{f.Id, f.Asset.ProviderId, f.Name, f.Severity, f.DetailedName, f.Version, f.FixedVersion, f.Path,
+			f.ExternalDetectionSource, fmt.Sprint(f.HasExploit), fmt.Sprint(f.HasCisaKevExploit), epss, f.ExternalFindingLink, f.Remediation})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// severityOrder sorts the severities from most to least severe.
+var severityOrder = []string{"Critical", "High", "Medium", "Low", "None"}
+
+func severityCounts(findings []assetFinding) map[string]int {
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	return counts
+}
@@ -0,0 +1,86 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtb75/wiz-scan/pkg/history"
+)
+
+// WriteRuns lists the recorded runs as a "table" or "json" report.
+func WriteRuns(w io.Writer, runs []history.Run, format string) error {
+	switch strings.ToLower(format) {
+	case "", "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "RUN\tTIME\tHOST\tPROVIDER ID\tFINDINGS")
+		for _, run := range runs {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\n", run.ID, run.Time.Format(time.RFC3339), dash(run.HostName), dash(run.ProviderID), run.Findings)
+		}
+		return tw.Flush()
+	case "json":
+		return writeJSON(w, runs)
+	default:
+		return fmt.Errorf("unsupported history format %q (supported: table, json)", format)
+	}
+}
+
+// WriteDiff renders the new, fixed and persisting findings between two runs as a "table" or "json" report.
+func WriteDiff(w io.Writer, diff *history.Diff, format string) error {
+	switch strings.ToLower(format) {
+	case "", "table":
+		return writeDiffTable(w, diff)
+	case "json":
+		return writeJSON(w, diff)
+	default:
+		return fmt.Errorf("unsupported history format %q (supported: table, json)", format)
+	}
+}
+
+func writeDiffTable(w io.Writer, diff *history.Diff) error {
+	fmt.Fprintf(w, "Run %d (%s) to run %d (%s): %d new, %d fixed, %d persisting\n\n",
+		diff.From.ID, diff.From.Time.Format(time.RFC3339), diff.To.ID, diff.To.Time.Format(time.RFC3339),
+		len(diff.New), len(diff.Fixed), len(diff.Persisting))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tCVE\tSEVERITY\tPACKAGE\tVERSION\tPATH\tFIRST SEEN\tLAST SEEN\tAGE")
+	for _, group := range []struct {
+		status  string
+		changes []history.Change
+	}{
+		{"New", diff.New},
+		{"Fixed", diff.Fixed},
+		{"Persisting", diff.Persisting},
+	} {
+		for _, c := range group.changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				group.status, c.CVE, c.Severity, c.Package, c.Version, dash(c.Path),
+				day(c.FirstSeen), day(c.LastSeen), age(c.FirstSeen, c.LastSeen))
+		}
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func day(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02")
+}
+
+// age is how long a finding was present, its time-to-fix once it is fixed.
+func age(first, last time.Time) string {
+	if first.IsZero() || last.IsZero() {
+		return "-"
+	}
+	return fmt.Sprintf("%dd", int(last.Sub(first).Hours()/24))
+}
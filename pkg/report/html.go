@@ -0,0 +1,81 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+)
+
+// htmlReport is a single file with inline styles so it can be attached to a ticket or a CI
+// run and opened without network access.
+var htmlReport = template.Must(template.New("findings").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>wiz-scan findings{{with .Meta.HostName}} - {{.}}{{end}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+h1 { font-size: 1.5em; }
+.meta { color: #59636e; margin-bottom: 1.5em; }
+.counts span { display: inline-block; padding: .3em .8em; margin-right: .5em; border-radius: 4px; color: #fff; }
+table { border-collapse: collapse; width: 100%; margin-top: 1.5em; font-size: .9em; }
+th, td { border: 1px solid #d1d9e0; padding: .4em .6em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+.Critical { background: #8b0000; } .High { background: #d1242f; } .Medium { background: #bc4c00; }
+.Low { background: #9a6700; } .None { background: #59636e; }
+td.sev span { color: #fff; padding: .1em .5em; border-radius: 3px; }
+details summary { cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>wiz-scan findings</h1>
+<div class="meta">
+{{with .Meta.HostName}}Host {{.}} · {{end}}{{if not .Meta.Timestamp.IsZero}}{{.Meta.Timestamp.Format "2006-01-02 15:04:05 MST"}} · {{end}}{{with .Meta.ToolVersion}}wiz-scan {{.}}{{end}}
+</div>
+<div class="counts">
+{{range .Counts}}<span class="{{.Severity}}">{{.Severity}}: {{.Count}}</span>{{end}}
+</div>
+{{if .Findings}}
+<table>
+<tr><th>Severity</th><th>CVE</th><th>Package</th><th>Version</th><th>Fixed</th><th>Path</th><th>Exploit</th><th>Details</th></tr>
+{{range .Findings}}<tr>
+<td class="sev"><span class="{{.Severity}}">{{.Severity}}</span></td>
+<td>{{if .ExternalFindingLink}}<a href="{{.ExternalFindingLink}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td>
+<td>{{.DetailedName}}</td>
+<td>{{.Version}}</td>
+<td>{{.FixedVersion}}</td>
+<td>{{.Path}}</td>
+<td>{{if .HasCisaKevExploit}}KEV{{else if .HasExploit}}Yes{{end}}</td>
+<td><details><summary>Show</summary><p>{{.Description}}</p>{{with .Remediation}}<p>{{.}}</p>{{end}}</details></td>
+</tr>
+{{end}}</table>
+{{else}}
+<p>No vulnerabilities to publish.</p>
+{{end}}
+</body>
+</html>
+`))
+
+type severityCount struct {
+	Severity string
+	Count    int
+}
+
+func writeFindingsHTML(w io.Writer, data vulnerability.IntegrationData, meta Metadata) error {
+	findings := flatten(data)
+	counts := severityCounts(findings)
+	var ordered []severityCount
+	for _, severity := range severityOrder {
+		ordered = append(ordered, severityCount{Severity: severity, Count: counts[severity]})
+	}
+	if meta.Timestamp.IsZero() {
+		meta.Timestamp = time.Now()
+	}
+	return htmlReport.Execute(w, struct {
+		Meta     Metadata
+		Counts   []severityCount
+		Findings []assetFinding
+	}{meta, ordered, findings})
+}
@@ -0,0 +1,86 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Hostname  string          `xml:"hostname,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeFindingsJUnit reports every finding as a failed test case, one suite per asset, so
+// CI dashboards show them like test failures. An asset without findings gets one passing case.
+func writeFindingsJUnit(w io.Writer, data vulnerability.IntegrationData, meta Metadata) error {
+	suites := junitTestSuites{Name: "wiz-scan"}
+	for _, ds := range data.DataSources {
+		for _, asset := range ds.Assets {
+			suite := junitTestSuite{Name: asset.AssetIdentifier.ProviderId, Hostname: meta.HostName}
+			if !meta.Timestamp.IsZero() {
+				suite.Timestamp = meta.Timestamp.Format("2006-01-02T15:04:05")
+			}
+			for _, f := range asset.VulnerabilityFindings {
+				text := f.Description
+				if f.Remediation != "" {
+					text += "\n\n" + f.Remediation
+				}
+				suite.Cases = append(suite.Cases, junitTestCase{
+					ClassName: f.DetailedName,
+					Name:      fmt.Sprintf("%s in %s %s", f.Name, f.DetailedName, f.Version),
+					Failure: &junitFailure{
+						Message: fmt.Sprintf("%s %s vulnerability in %s %s", f.Severity, f.Name, f.DetailedName, f.Version),
+						Type:    f.Severity,
+						Text:    text,
+					},
+				})
+				suite.Failures++
+			}
+			if len(suite.Cases) == 0 {
+				suite.Cases = append(suite.Cases, junitTestCase{ClassName: "wiz-scan", Name: "no vulnerabilities to publish"})
+			}
+			suite.Tests = len(suite.Cases)
+			suites.Tests += suite.Tests
+			suites.Failures += suite.Failures
+			suites.Suites = append(suites.Suites, suite)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
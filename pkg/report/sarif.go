@@ -0,0 +1,153 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+)
+
+// SARIF 2.1.0 types, limited to what wiz-scan reports.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string                 `json:"id"`
+	ShortDescription sarifMessage           `json:"shortDescription"`
+	HelpURI          string                 `json:"helpUri,omitempty"`
+	Properties       map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID              string                 `json:"ruleId"`
+	Level               string                 `json:"level"`
+	Message             sarifMessage           `json:"message"`
+	Locations           []sarifLocation        `json:"locations,omitempty"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints"`
+	Properties          map[string]interface{} `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifLevels maps Wiz severities to SARIF result levels.
+var sarifLevels = map[string]string{
+	"Critical": "error",
+	"High":     "error",
+	"Medium":   "warning",
+	"Low":      "note",
+	"None":     "note",
+}
+
+// sarifSecurityScores are the security-severity values code scanning tools use to rank rules
+// when a finding has no CVSS score.
+var sarifSecurityScores = map[string]float64{
+	"Critical": 9.5,
+	"High":     8.0,
+	"Medium":   5.5,
+	"Low":      2.0,
+	"None":     0.0,
+}
+
+func writeFindingsSARIF(w io.Writer, data vulnerability.IntegrationData, meta Metadata) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "wiz-scan",
+			Version:        meta.ToolVersion,
+			InformationURI: "https://github.com/jtb75/wiz-scan",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	rules := make(map[string]bool)
+	for _, f := range flatten(data) {
+		if !rules[f.Name] {
+			rules[f.Name] = true
+			score := sarifSecurityScores[f.Severity]
+			if f.Score > 0 {
+				score = f.Score
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               f.Name,
+				ShortDescription: sarifMessage{Text: fmt.Sprintf("%s (%s)", f.Name, f.Severity)},
+				HelpURI:          f.ExternalFindingLink,
+				Properties: map[string]interface{}{
+					"security-severity": fmt.Sprintf("%.1f", score),
+					"tags":              []string{"security", "vulnerability"},
+				},
+			})
+		}
+
+		result := sarifResult{
+			RuleID:              f.Name,
+			Level:               sarifLevels[f.Severity],
+			Message:             sarifMessage{Text: f.Description},
+			PartialFingerprints: map[string]string{"wizFindingId": f.Id},
+			Properties: map[string]interface{}{
+				"package":      f.DetailedName,
+				"version":      f.Version,
+				"fixedVersion": f.FixedVersion,
+				"severity":     f.Severity,
+				"providerId":   f.Asset.ProviderId,
+			},
+		}
+		if result.Level == "" {
+			result.Level = "warning"
+		}
+		if f.Path != "" {
+			result.Locations = []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: sarifURI(f.Path)},
+			}}}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return writeJSON(w, sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifURI turns a host path into a file URI, which SARIF expects for artifact locations.
+func sarifURI(path string) string {
+	path = strings.ReplaceAll(path, "\\", "/")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "file://" + strings.ReplaceAll(path, " ", "%20")
+}
@@ -0,0 +1,94 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtb75/wiz-scan/pkg/vulnerability"
+)
+
+// WriteVerdicts renders the comparison verdicts as a "table", "json" or "markdown" report.
+func WriteVerdicts(w io.Writer, verdicts []vulnerability.Verdict, format string) error {
+	switch strings.ToLower(format) {
+	case "", "table":
+		return writeVerdictTable(w, verdicts)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(verdicts)
+	case "markdown", "md":
+		return writeVerdictMarkdown(w, verdicts)
+	default:
+		return fmt.Errorf("unsupported report format %q (supported: table, json, markdown)", format)
+	}
+}
+
+// WriteVerdictsFile writes the verdict report to path, or to stdout when path is empty.
+func WriteVerdictsFile(path string, verdicts []vulnerability.Verdict, format string) (err error) {
+	if path == "" {
+		return WriteVerdicts(os.Stdout, verdicts, format)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close report file: %w", closeErr)
+		}
+	}()
+
+	return WriteVerdicts(file, verdicts, format)
+}
+
+func writeVerdictTable(w io.Writer, verdicts []vulnerability.Verdict) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERDICT\tCVE\tSEVERITY\tTYPE\tPACKAGE\tVERSION\tFIXED\tPATH\tWIZ FINDING\tREASON")
+	for _, v := range verdicts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			v.Action, v.CVE, v.Severity, v.Type, v.Package, v.Version, dash(v.FixedVersion), dash(v.Path), dash(v.WizFindingID), v.Reason)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, summary(verdicts))
+	return tw.Flush()
+}
+
+func writeVerdictMarkdown(w io.Writer, verdicts []vulnerability.Verdict) error {
+	var b strings.Builder
+	b.WriteString("# wiz-scan comparison report\n\n")
+	b.WriteString(summary(verdicts) + "\n\n")
+	b.WriteString("| Verdict | CVE | Severity | Type | Package | Version | Fixed | Path | Wiz finding | Reason |\n")
+	b.WriteString("|---|---|---|---|---|---|---|---|---|---|\n")
+	for _, v := range verdicts {
+		cells := []string{string(v.Action), v.CVE, v.Severity, v.Type, v.Package, v.Version, dash(v.FixedVersion), dash(v.Path), dash(v.WizFindingID), v.Reason}
+		for i, cell := range cells {
+			cells[i] = strings.ReplaceAll(cell, "|", "\\|")
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// summary counts the verdicts per action.
+func summary(verdicts []vulnerability.Verdict) string {
+	counts := make(map[vulnerability.Action]int)
+	for _, v := range verdicts {
+		counts[v.Action]++
+	}
+	return fmt.Sprintf("%d vulnerabilities: %d to add, %d to keep, %d ignored, %d suppressed, %d dropped by policy",
+		len(verdicts), counts[vulnerability.ActionAdd], counts[vulnerability.ActionKeep], counts[vulnerability.ActionIgnore],
+		counts[vulnerability.ActionSuppress], counts[vulnerability.ActionDrop])
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
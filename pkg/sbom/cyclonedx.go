@@ -0,0 +1,155 @@
+package sbom
+
+import (
+	"strings"
+)
+
+// CycloneDX JSON document, limited to the parts wiz-scan reads and writes.
+type cdxBOM struct {
+	BOMFormat       string             `json:"bomFormat"`
+	SpecVersion     string             `json:"specVersion"`
+	SerialNumber    string             `json:"serialNumber,omitempty"`
+	Version         int                `json:"version"`
+	Metadata        *cdxMetadata       `json:"metadata,omitempty"`
+	Components      []cdxComponent     `json:"components,omitempty"`
+	Vulnerabilities []cdxVulnerability `json:"vulnerabilities,omitempty"`
+}
+
+type cdxMetadata struct {
+	Timestamp string        `json:"timestamp,omitempty"`
+	Tools     []cdxTool     `json:"tools,omitempty"`
+	Component *cdxComponent `json:"component,omitempty"`
+}
+
+type cdxTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type cdxComponent struct {
+	BOMRef     string         `json:"bom-ref,omitempty"`
+	Type       string         `json:"type"`
+	Group      string         `json:"group,omitempty"`
+	Name       string         `json:"name"`
+	Version    string         `json:"version,omitempty"`
+	PURL       string         `json:"purl,omitempty"`
+	CPE        string         `json:"cpe,omitempty"`
+	Properties []cdxProperty  `json:"properties,omitempty"`
+	Evidence   *cdxEvidence   `json:"evidence,omitempty"`
+	Components []cdxComponent `json:"components,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxEvidence struct {
+	Occurrences []cdxOccurrence `json:"occurrences,omitempty"`
+}
+
+type cdxOccurrence struct {
+	Location string `json:"location"`
+}
+
+type cdxVulnerability struct {
+	BOMRef         string       `json:"bom-ref,omitempty"`
+	ID             string       `json:"id"`
+	Source         *cdxSource   `json:"source,omitempty"`
+	Ratings        []cdxRating  `json:"ratings,omitempty"`
+	Description    string       `json:"description,omitempty"`
+	Recommendation string       `json:"recommendation,omitempty"`
+	Analysis       *cdxAnalysis `json:"analysis,omitempty"`
+	Affects        []cdxAffect  `json:"affects,omitempty"`
+}
+
+type cdxSource struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type cdxRating struct {
+	Score    float64 `json:"score,omitempty"`
+	Severity string  `json:"severity,omitempty"`
+	Method   string  `json:"method,omitempty"`
+	Vector   string  `json:"vector,omitempty"`
+}
+
+type cdxAnalysis struct {
+	State         string `json:"state,omitempty"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type cdxAffect struct {
+	Ref      string              `json:"ref"`
+	Versions []cdxAffectVersions `json:"versions,omitempty"`
+}
+
+type cdxAffectVersions struct {
+	Version string `json:"version,omitempty"`
+	Range   string `json:"range,omitempty"`
+	Status  string `json:"status,omitempty"`
+}
+
+// Properties tools use to record where a component was found on disk.
+var cdxLocationProperties = []string{
+	"syft:location:0:path",
+	"aquasecurity:trivy:FilePath",
+	"wiz-scan:path",
+}
+
+// location returns the first on-disk location recorded for the component.
+func (c cdxComponent) location() string {
+	if c.Evidence != nil {
+		for _, occurrence := range c.Evidence.Occurrences {
+			if occurrence.Location != "" {
+				return occurrence.Location
+			}
+		}
+	}
+	for _, name := range cdxLocationProperties {
+		for _, property := range c.Properties {
+			if property.Name == name && property.Value != "" {
+				return property.Value
+			}
+		}
+	}
+	return ""
+}
+
+// suppressed reports whether the VEX analysis says the vulnerability doesn't apply.
+func (v cdxVulnerability) suppressed() bool {
+	if v.Analysis == nil {
+		return false
+	}
+	switch v.Analysis.State {
+	case "not_affected", "false_positive", "resolved", "resolved_with_pedigree":
+		return true
+	}
+	return false
+}
+
+// severity returns the highest rated severity and score of the vulnerability.
+func (v cdxVulnerability) severity() (string, float64) {
+	order := map[string]int{"none": 0, "info": 0, "low": 1, "medium": 2, "high": 3, "critical": 4}
+	severity, score, rank := "", 0.0, -1
+	for _, rating := range v.Ratings {
+		if rating.Score > score {
+			score = rating.Score
+		}
+		if r, ok := order[strings.ToLower(rating.Severity)]; ok && r > rank {
+			severity, rank = rating.Severity, r
+		}
+	}
+	return severity, score
+}
+
+// fixedVersion returns the first version the affects section marks as unaffected for ref.
+func (a cdxAffect) fixedVersion() string {
+	for _, version := range a.Versions {
+		if version.Status == "unaffected" && version.Version != "" {
+			return version.Version
+		}
+	}
+	return ""
+}
@@ -0,0 +1,259 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jtb75/wiz-scan/pkg/ecosystem"
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// Metadata describes the host and tool an exported SBOM was produced by.
+type Metadata struct {
+	HostName    string
+	ToolVersion string
+	Timestamp   time.Time
+}
+
+// inventoryItem is a package found on the host together with the vulnerabilities that affect it.
+type inventoryItem struct {
+	ref             string
+	kind            string
+	name            string
+	version         string
+	path            string
+	purl            string
+	cpe             string
+	vulnerabilities []wizcli.Vulnerability
+}
+
+// LibraryPURL builds the package URL of a library, inferring its ecosystem from its name and path.
+func LibraryPURL(name, version, path string) PackageURL {
+	p := PackageURL{Type: string(ecosystem.DetectLibrary(name, path)), Name: name, Version: version}
+	switch p.Type {
+	case string(ecosystem.Maven):
+		if group, artifact, ok := strings.Cut(name, ":"); ok {
+			p.Namespace, p.Name = group, artifact
+		}
+	case string(ecosystem.Npm), string(ecosystem.Go), string(ecosystem.Composer):
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			p.Namespace, p.Name = name[:i], name[i+1:]
+		}
+	case "":
+		p.Type = "generic"
+	}
+	return p
+}
+
+// OSPackagePURL builds the package URL of a package from the running operating system's package manager.
+func OSPackagePURL(name, version string) PackageURL {
+	eco, distro := ecosystem.DetectOS()
+	if eco == ecosystem.Unknown {
+		return PackageURL{Type: "generic", Name: name, Version: version}
+	}
+	p := PackageURL{Type: string(eco), Namespace: distro, Name: name, Version: version}
+	if release := ecosystem.DetectOSRelease(); release != "" {
+		p.Qualifiers = map[string]string{"distro": release}
+	}
+	return p
+}
+
+// inventory flattens scan results into one item per package and location.
+func inventory(results *wizcli.AggregatedScanResults) []*inventoryItem {
+	var items []*inventoryItem
+	byKey := make(map[string]*inventoryItem)
+
+	add := func(kind, name, version, path, purl, cpe string, vulns ...wizcli.Vulnerability) {
+		key := kind + "\x00" + name + "\x00" + version + "\x00" + path
+		item, ok := byKey[key]
+		if !ok {
+			item = &inventoryItem{
+				ref:     fmt.Sprintf("component-%d", len(items)+1),
+				kind:    kind,
+				name:    name,
+				version: version,
+				path:    path,
+				purl:    purl,
+				cpe:     cpe,
+			}
+			byKey[key] = item
+			items = append(items, item)
+		}
+		item.vulnerabilities = append(item.vulnerabilities, vulns...)
+	}
+
+	for _, pkg := range results.OsPackages {
+		add("library", pkg.Name, pkg.Version, "", OSPackagePURL(pkg.Name, pkg.Version).String(), "", pkg.Vulnerabilities...)
+	}
+	for _, lib := range results.Libraries {
+		add("library", lib.Name, lib.Version, lib.Path, LibraryPURL(lib.Name, lib.Version, lib.Path).String(), "", lib.Vulnerabilities...)
+	}
+	for _, app := range results.Applications {
+		for _, detail := range app.Vulnerabilities {
+			path, _ := detail.Path.(string)
+			add("application", app.Name, detail.Version, path, "", "", detail.Vulnerability)
+		}
+	}
+	for _, cpe := range results.Cpes {
+		cpeName := ""
+		if strings.HasPrefix(cpe.Name, "cpe:") {
+			cpeName = cpe.Name
+		}
+		add("application", cpe.Name, cpe.Version, cpe.Path, "", cpeName, cpe.Vulnerabilities...)
+	}
+
+	return items
+}
+
+// WriteCycloneDX writes a CycloneDX 1.5 JSON document of every package in results,
+// with PURLs, file locations and the vulnerabilities that affect each package.
+func WriteCycloneDX(w io.Writer, results *wizcli.AggregatedScanResults, meta Metadata) error {
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid.New().String(),
+		Version:      1,
+		Metadata: &cdxMetadata{
+			Timestamp: meta.Timestamp.UTC().Format(time.RFC3339),
+			Tools:     []cdxTool{{Name: "wiz-scan", Version: meta.ToolVersion}},
+			Component: &cdxComponent{Type: "device", Name: meta.HostName},
+		},
+	}
+
+	vulnerabilities := make(map[string]*cdxVulnerability)
+	for _, item := range inventory(results) {
+		component := cdxComponent{
+			BOMRef:  item.ref,
+			Type:    item.kind,
+			Name:    item.name,
+			Version: item.version,
+			PURL:    item.purl,
+			CPE:     item.cpe,
+		}
+		if item.path != "" {
+			component.Evidence = &cdxEvidence{Occurrences: []cdxOccurrence{{Location: item.path}}}
+		}
+		bom.Components = append(bom.Components, component)
+
+		for _, vuln := range item.vulnerabilities {
+			v, ok := vulnerabilities[vuln.Name]
+			if !ok {
+				v = &cdxVulnerability{ID: vuln.Name}
+				if vuln.Source != "" {
+					v.Source = &cdxSource{URL: vuln.Source}
+				}
+				if description, ok := vuln.Description.(string); ok {
+					v.Description = description
+				}
+				if vuln.Severity != "" || vuln.Score > 0 {
+					v.Ratings = []cdxRating{{Severity: strings.ToLower(vuln.Severity), Score: vuln.Score}}
+				}
+				vulnerabilities[vuln.Name] = v
+			}
+			affect := cdxAffect{Ref: item.ref}
+			if vuln.FixedVersion != "" {
+				affect.Versions = []cdxAffectVersions{
+					{Version: item.version, Status: "affected"},
+					{Version: vuln.FixedVersion, Status: "unaffected"},
+				}
+			}
+			v.Affects = append(v.Affects, affect)
+		}
+	}
+
+	ids := make([]string, 0, len(vulnerabilities))
+	for id := range vulnerabilities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		bom.Vulnerabilities = append(bom.Vulnerabilities, *vulnerabilities[id])
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(bom)
+}
+
+// WriteSPDX writes an SPDX 2.3 JSON document of every package in results.
+// Vulnerabilities are recorded as SECURITY advisory references on the affected packages.
+func WriteSPDX(w io.Writer, results *wizcli.AggregatedScanResults, meta Metadata) error {
+	creator := "Tool: wiz-scan"
+	if meta.ToolVersion != "" {
+		creator += "-" + meta.ToolVersion
+	}
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              meta.HostName,
+		DocumentNamespace: "https://wiz-scan/spdx/" + meta.HostName + "-" + uuid.New().String(),
+		CreationInfo: spdxCreationInfo{
+			Created:  meta.Timestamp.UTC().Format(time.RFC3339),
+			Creators: []string{creator},
+		},
+	}
+
+	for i, item := range inventory(results) {
+		pkg := spdxPackage{
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i+1),
+			Name:             item.name,
+			VersionInfo:      item.version,
+			PackageFileName:  item.path,
+			DownloadLocation: "NOASSERTION",
+		}
+		if item.purl != "" {
+			pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: item.purl})
+		}
+		if item.cpe != "" {
+			pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{ReferenceCategory: "SECURITY", ReferenceType: "cpe23Type", ReferenceLocator: item.cpe})
+		}
+		for _, vuln := range item.vulnerabilities {
+			locator := vuln.Source
+			if locator == "" {
+				locator = "https://nvd.nist.gov/vuln/detail/" + vuln.Name
+			}
+			pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{ReferenceCategory: "SECURITY", ReferenceType: "advisory", ReferenceLocator: locator})
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{SPDXElementID: doc.SPDXID, RelationshipType: "DESCRIBES", RelatedSPDXElement: pkg.SPDXID})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// Export writes results to path in the named format ("cyclonedx" or "spdx").
+func Export(path, format string, results *wizcli.AggregatedScanResults, meta Metadata) (err error) {
+	var write func(io.Writer, *wizcli.AggregatedScanResults, Metadata) error
+	switch strings.ToLower(format) {
+	case "", "cyclonedx":
+		write = WriteCycloneDX
+	case "spdx":
+		write = WriteSPDX
+	default:
+		return fmt.Errorf("unsupported SBOM format %q (supported: cyclonedx, spdx)", format)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create SBOM file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close SBOM file: %w", closeErr)
+		}
+	}()
+
+	if err := write(file, results, meta); err != nil {
+		return fmt.Errorf("failed to write SBOM: %w", err)
+	}
+	return nil
+}
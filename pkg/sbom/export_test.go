@@ -0,0 +1,135 @@
+package sbom
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// loadedPackage is a package of loaded results with the parts an exported SBOM keeps.
+type loadedPackage struct {
+	os              bool
+	version         string
+	vulnerabilities map[string]wizcli.Vulnerability
+}
+
+func exportedResults() *wizcli.AggregatedScanResults {
+	log4shell := wizcli.Vulnerability{Name: "CVE-2021-44228", Severity: "CRITICAL", FixedVersion: "2.15.0", Score: 10,
+		Source: "https://nvd.nist.gov/vuln/detail/CVE-2021-44228", Description: "Remote code execution through JNDI lookups"}
+	return &wizcli.AggregatedScanResults{
+		OsPackages: []wizcli.OsPackage{{
+			Name:    "openssl",
+			Version: "3.0.11-1~deb12u2",
+			Vulnerabilities: []wizcli.Vulnerability{
+				{Name: "CVE-2024-0727", Severity: "MEDIUM", FixedVersion: "3.0.13-1~deb12u1", Score: 5.5},
+			},
+			DetectionMethod: wizcli.DetectionMethodPackage,
+		}},
+		Libraries: []wizcli.Library{
+			{
+				Name:            "org.apache.logging.log4j:log4j-core",
+				Version:         "2.14.1",
+				Path:            "/opt/app/lib/log4j-core-2.14.1.jar",
+				Vulnerabilities: []wizcli.Vulnerability{log4shell, {Name: "CVE-2021-45046", Severity: "CRITICAL", FixedVersion: "2.16.0", Score: 9}},
+				DetectionMethod: wizcli.DetectionMethodLibrary,
+			},
+			{
+				// The same vulnerability in a second copy of the library
+				Name:            "org.apache.logging.log4j:log4j-core",
+				Version:         "2.14.1",
+				Path:            "/opt/other/log4j-core-2.14.1.jar",
+				Vulnerabilities: []wizcli.Vulnerability{log4shell},
+				DetectionMethod: wizcli.DetectionMethodLibrary,
+			},
+			{
+				Name:            "@babel/traverse",
+				Version:         "7.22.5",
+				Path:            "/srv/web/node_modules/@babel/traverse/package.json",
+				Vulnerabilities: []wizcli.Vulnerability{{Name: "CVE-2023-45133", Severity: "CRITICAL", Score: 9.3}},
+				DetectionMethod: wizcli.DetectionMethodLibrary,
+			},
+			{
+				Name:            "golang.org/x/net",
+				Version:         "v0.17.0",
+				Path:            "/usr/local/bin/app",
+				DetectionMethod: wizcli.DetectionMethodLibrary,
+			},
+		},
+	}
+}
+
+func loadedPackages(results *wizcli.AggregatedScanResults) map[string]loadedPackage {
+	packages := make(map[string]loadedPackage)
+	add := func(os bool, name, version, path string, vulns []wizcli.Vulnerability) {
+		p := loadedPackage{os: os, version: version, vulnerabilities: make(map[string]wizcli.Vulnerability)}
+		for _, v := range vulns {
+			description, _ := v.Description.(string)
+			p.vulnerabilities[v.Name] = wizcli.Vulnerability{Name: v.Name, Severity: strings.ToLower(v.Severity),
+				FixedVersion: v.FixedVersion, Score: v.Score, Source: v.Source, Description: description}
+		}
+		packages[name+" "+path] = p
+	}
+	for _, pkg := range results.OsPackages {
+		add(true, pkg.Name, pkg.Version, "", pkg.Vulnerabilities)
+	}
+	for _, lib := range results.Libraries {
+		add(false, lib.Name, lib.Version, lib.Path, lib.Vulnerabilities)
+	}
+	return packages
+}
+
+func TestExportLoadRoundTrip(t *testing.T) {
+	meta := Metadata{HostName: "web-01", ToolVersion: "1.2.3", Timestamp: time.Date(2026, 1, 14, 10, 0, 0, 0, time.UTC)}
+	results := exportedResults()
+
+	// OS packages are only exported with an OS package URL when the running host's distribution is known
+	osKnown := OSPackagePURL("openssl", "3.0.11-1~deb12u2").IsOSPackage()
+
+	for _, format := range []string{"cyclonedx", "spdx"} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sbom."+format+".json")
+			if err := Export(path, format, results, meta); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := Load([]string{path})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := loadedPackages(results)
+			if format == "spdx" {
+				// SPDX only carries the inventory; vulnerabilities come from a CycloneDX VEX
+				for key, p := range want {
+					p.vulnerabilities = map[string]wizcli.Vulnerability{}
+					want[key] = p
+				}
+			}
+			openssl := want["openssl "]
+			openssl.os = osKnown
+			want["openssl "] = openssl
+
+			got := loadedPackages(loaded)
+			if len(got) != len(want) {
+				t.Errorf("loaded %d packages, want %d: %+v", len(got), len(want), got)
+			}
+			for key, w := range want {
+				if g, ok := got[key]; !ok {
+					t.Errorf("%s is missing", key)
+				} else if !reflect.DeepEqual(g, w) {
+					t.Errorf("%s is\n  %+v, want\n  %+v", key, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestExportUnsupportedFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sbom.json")
+	if err := Export(path, "swid", exportedResults(), Metadata{}); err == nil {
+		t.Error("exported an SBOM in an unsupported format")
+	}
+}
@@ -0,0 +1,200 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// component is a package found in one of the loaded documents.
+type component struct {
+	name            string
+	version         string
+	path            string
+	os              bool
+	vulnerabilities []wizcli.Vulnerability
+}
+
+// loader collects components from several documents so vulnerabilities from one
+// document (e.g. a standalone CycloneDX VEX) can be attached to components of another.
+type loader struct {
+	components      []*component
+	byRef           map[string]*component
+	byPURL          map[string]*component
+	vulnerabilities []cdxVulnerability
+}
+
+// Load reads CycloneDX and SPDX JSON documents and maps their packages and the
+// CycloneDX vulnerabilities that affect them into wizcli results.
+func Load(paths []string) (*wizcli.AggregatedScanResults, error) {
+	l := &loader{
+		byRef:  make(map[string]*component),
+		byPURL: make(map[string]*component),
+	}
+
+	for _, path := range paths {
+		if err := l.loadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to load SBOM %s: %w", path, err)
+		}
+	}
+	l.attachVulnerabilities()
+
+	results := &wizcli.AggregatedScanResults{}
+	for _, c := range l.components {
+		if c.os {
+			results.OsPackages = append(results.OsPackages, wizcli.OsPackage{
+				Name:            c.name,
+				Version:         c.version,
+				Vulnerabilities: c.vulnerabilities,
+				DetectionMethod: wizcli.DetectionMethodPackage,
+			})
+			continue
+		}
+		results.Libraries = append(results.Libraries, wizcli.Library{
+			Name:            c.name,
+			Version:         c.version,
+			Path:            c.path,
+			Vulnerabilities: c.vulnerabilities,
+			DetectionMethod: wizcli.DetectionMethodLibrary,
+		})
+	}
+
+	return results, nil
+}
+
+func (l *loader) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var header struct {
+		BOMFormat   string `json:"bomFormat"`
+		SPDXVersion string `json:"spdxVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return fmt.Errorf("not a JSON document: %w", err)
+	}
+
+	switch {
+	case header.BOMFormat == "CycloneDX":
+		var bom cdxBOM
+		if err := json.Unmarshal(data, &bom); err != nil {
+			return fmt.Errorf("invalid CycloneDX document: %w", err)
+		}
+		l.addCycloneDXComponents(bom.Components)
+		l.vulnerabilities = append(l.vulnerabilities, bom.Vulnerabilities...)
+	case strings.HasPrefix(header.SPDXVersion, "SPDX-"):
+		var doc spdxDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid SPDX document: %w", err)
+		}
+		for _, pkg := range doc.Packages {
+			l.addComponent(pkg.SPDXID, pkg.Name, pkg.VersionInfo, pkg.purl(), pkg.PackageFileName)
+		}
+	default:
+		return fmt.Errorf("unrecognized SBOM format (expected CycloneDX or SPDX JSON)")
+	}
+
+	return nil
+}
+
+func (l *loader) addCycloneDXComponents(components []cdxComponent) {
+	for _, c := range components {
+		if c.Type != "operating-system" {
+			l.addComponent(c.BOMRef, c.Name, c.Version, c.PURL, c.location())
+		}
+		l.addCycloneDXComponents(c.Components)
+	}
+}
+
+// addComponent records a package, merging it with an already known package with the same PURL and path.
+func (l *loader) addComponent(ref, name, version, rawPURL, path string) {
+	c := &component{name: name, version: version, path: path}
+
+	purlKey := ""
+	if purl, err := ParsePURL(rawPURL); err == nil {
+		c.name = purl.LibraryName()
+		if purl.Version != "" {
+			c.version = purl.Version
+		}
+		c.os = purl.IsOSPackage()
+		purlKey = purl.matchKey()
+	}
+	if c.name == "" || c.version == "" {
+		// Documents describe themselves and their files as packages too; only versioned packages matter here
+		return
+	}
+
+	if existing, ok := l.byPURL[purlKey+"\x00"+path]; ok && purlKey != "" {
+		c = existing
+	} else {
+		l.components = append(l.components, c)
+		if purlKey != "" {
+			l.byPURL[purlKey+"\x00"+path] = c
+			if _, ok := l.byPURL[purlKey]; !ok {
+				l.byPURL[purlKey] = c
+			}
+		}
+	}
+	if ref != "" {
+		l.byRef[ref] = c
+	}
+}
+
+// resolve finds the component a CycloneDX affects reference points to.
+// References are bom-refs, BOM-Link URNs ending in #bom-ref, or package URLs.
+func (l *loader) resolve(ref string) *component {
+	if c, ok := l.byRef[ref]; ok {
+		return c
+	}
+	if strings.HasPrefix(ref, "urn:cdx:") {
+		if i := strings.LastIndex(ref, "#"); i >= 0 {
+			return l.byRef[ref[i+1:]]
+		}
+	}
+	if purl, err := ParsePURL(ref); err == nil {
+		return l.byPURL[purl.matchKey()]
+	}
+	return nil
+}
+
+func (l *loader) attachVulnerabilities() {
+	for _, v := range l.vulnerabilities {
+		if v.suppressed() {
+			continue
+		}
+		severity, score := v.severity()
+		source := ""
+		if v.Source != nil {
+			source = v.Source.URL
+		}
+
+		for _, affect := range v.Affects {
+			c := l.resolve(affect.Ref)
+			if c == nil || hasVulnerability(c, v.ID) {
+				continue
+			}
+			c.vulnerabilities = append(c.vulnerabilities, wizcli.Vulnerability{
+				Name:         v.ID,
+				Severity:     severity,
+				FixedVersion: affect.fixedVersion(),
+				Source:       source,
+				Description:  v.Description,
+				Score:        score,
+			})
+		}
+	}
+}
+
+func hasVulnerability(c *component, id string) bool {
+	for _, v := range c.vulnerabilities {
+		if v.Name == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package sbom
+
+import (
+	"testing"
+
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+func TestLoadCycloneDX(t *testing.T) {
+	results, err := Load([]string{"testdata/nested.cdx.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The operating system and the unversioned application are not packages
+	if len(results.Libraries) != 1 || len(results.OsPackages) != 1 {
+		t.Fatalf("loaded %d libraries and %d OS packages, want 1 and 1: %+v", len(results.Libraries), len(results.OsPackages), results)
+	}
+
+	lib := results.Libraries[0]
+	if lib.Name != "org.springframework:spring-webmvc" || lib.Version != "5.3.17" ||
+		lib.Path != "/opt/shop/shop.war/WEB-INF/lib/spring-webmvc-5.3.17.jar" || lib.DetectionMethod != wizcli.DetectionMethodLibrary {
+		t.Errorf("nested library is %+v", lib)
+	}
+	if len(lib.Vulnerabilities) != 1 {
+		t.Fatalf("spring-webmvc has %d vulnerabilities, want 1", len(lib.Vulnerabilities))
+	}
+	if v := lib.Vulnerabilities[0]; v.Name != "CVE-2022-22965" || v.Severity != "critical" || v.Score != 9.8 || v.FixedVersion != "5.3.18" {
+		t.Errorf("vulnerability referenced by BOM-Link is %+v", v)
+	}
+
+	pkg := results.OsPackages[0]
+	if pkg.Name != "zlib1g" || pkg.Version != "1:1.2.13.dfsg-1" || pkg.DetectionMethod != wizcli.DetectionMethodPackage {
+		t.Errorf("OS package is %+v", pkg)
+	}
+	// The false positive is dropped and the package URL reference matches without qualifiers
+	if len(pkg.Vulnerabilities) != 1 || pkg.Vulnerabilities[0].Name != "CVE-2022-37434" || pkg.Vulnerabilities[0].Severity != "CRITICAL" {
+		t.Errorf("zlib1g vulnerabilities are %+v", pkg.Vulnerabilities)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := Load([]string{"testdata/missing.cdx.json"}); err == nil {
+		t.Error("loaded a missing document")
+	}
+	if _, err := Load([]string{"load_test.go"}); err == nil {
+		t.Error("loaded a document that isn't JSON")
+	}
+}
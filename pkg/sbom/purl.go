@@ -0,0 +1,148 @@
+package sbom
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// PackageURL is a parsed package URL (https://github.com/package-url/purl-spec).
+type PackageURL struct {
+	Type       string
+	Namespace  string
+	Name       string
+	Version    string
+	Qualifiers map[string]string
+	Subpath    string
+}
+
+// Package URL types managed by the operating system package manager.
+var osPackageTypes = map[string]bool{
+	"deb":  true,
+	"rpm":  true,
+	"apk":  true,
+	"alpm": true,
+}
+
+// ParsePURL parses a package URL such as pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1.
+func ParsePURL(s string) (PackageURL, error) {
+	var p PackageURL
+
+	rest, ok := strings.CutPrefix(s, "pkg:")
+	if !ok {
+		return p, fmt.Errorf("invalid package URL %q: missing pkg: scheme", s)
+	}
+	rest = strings.TrimLeft(rest, "/")
+
+	if i := strings.LastIndex(rest, "#"); i >= 0 {
+		p.Subpath = strings.Trim(unescape(rest[i+1:]), "/")
+		rest = rest[:i]
+	}
+	if i := strings.LastIndex(rest, "?"); i >= 0 {
+		p.Qualifiers = make(map[string]string)
+		for _, pair := range strings.Split(rest[i+1:], "&") {
+			key, value, _ := strings.Cut(pair, "=")
+			if key != "" {
+				p.Qualifiers[strings.ToLower(key)] = unescape(value)
+			}
+		}
+		rest = rest[:i]
+	}
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		p.Version = unescape(rest[i+1:])
+		rest = rest[:i]
+	}
+
+	typ, path, ok := strings.Cut(rest, "/")
+	if !ok || typ == "" {
+		return p, fmt.Errorf("invalid package URL %q: missing type or name", s)
+	}
+	p.Type = strings.ToLower(typ)
+
+	path = strings.Trim(path, "/")
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		p.Namespace = unescape(path[:i])
+		p.Name = unescape(path[i+1:])
+	} else {
+		p.Name = unescape(path)
+	}
+	if p.Name == "" {
+		return p, fmt.Errorf("invalid package URL %q: missing name", s)
+	}
+
+	return p, nil
+}
+
+// String formats the package URL in its canonical form with sorted qualifiers.
+func (p PackageURL) String() string {
+	var b strings.Builder
+	b.WriteString("pkg:")
+	b.WriteString(p.Type)
+	b.WriteString("/")
+	if p.Namespace != "" {
+		for _, segment := range strings.Split(p.Namespace, "/") {
+			b.WriteString(escape(segment))
+			b.WriteString("/")
+		}
+	}
+	b.WriteString(escape(p.Name))
+	if p.Version != "" {
+		b.WriteString("@")
+		b.WriteString(escape(p.Version))
+	}
+	if len(p.Qualifiers) > 0 {
+		keys := make([]string, 0, len(p.Qualifiers))
+		for key := range p.Qualifiers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			if i == 0 {
+				b.WriteString("?")
+			} else {
+				b.WriteString("&")
+			}
+			b.WriteString(key + "=" + escape(p.Qualifiers[key]))
+		}
+	}
+	if p.Subpath != "" {
+		b.WriteString("#" + p.Subpath)
+	}
+	return b.String()
+}
+
+// IsOSPackage reports whether the package comes from the OS package manager.
+func (p PackageURL) IsOSPackage() bool {
+	return osPackageTypes[p.Type]
+}
+
+// LibraryName returns the package name the way wizcli reports it for the ecosystem.
+func (p PackageURL) LibraryName() string {
+	switch {
+	case p.Namespace == "":
+		return p.Name
+	case p.Type == "maven":
+		return p.Namespace + ":" + p.Name
+	case p.Type == "npm", p.Type == "golang", p.Type == "composer":
+		return p.Namespace + "/" + p.Name
+	default:
+		return p.Name
+	}
+}
+
+// matchKey identifies the package without qualifiers so the same package matches across documents.
+func (p PackageURL) matchKey() string {
+	return strings.ToLower(p.Type + "/" + p.Namespace + "/" + p.Name + "@" + p.Version)
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "@", "%40")
+}
+
+func unescape(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package sbom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePURL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    PackageURL
+		library string
+		os      bool
+	}{
+		{
+			in:      "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1",
+			want:    PackageURL{Type: "maven", Namespace: "org.apache.logging.log4j", Name: "log4j-core", Version: "2.14.1"},
+			library: "org.apache.logging.log4j:log4j-core",
+		},
+		{
+			in:      "pkg:npm/%40babel/core@7.23.0",
+			want:    PackageURL{Type: "npm", Namespace: "@babel", Name: "core", Version: "7.23.0"},
+			library: "@babel/core",
+		},
+		{
+			in:      "pkg:golang/golang.org/x/net@v0.17.0#http2/hpack",
+			want:    PackageURL{Type: "golang", Namespace: "golang.org/x", Name: "net", Version: "v0.17.0", Subpath: "http2/hpack"},
+			library: "golang.org/x/net",
+		},
+		{
+			in: "pkg:deb/debian/openssl@3.0.11-1~deb12u2?arch=amd64&Distro=debian-12",
+			want: PackageURL{Type: "deb", Namespace: "debian", Name: "openssl", Version: "3.0.11-1~deb12u2",
+				Qualifiers: map[string]string{"arch": "amd64", "distro": "debian-12"}},
+			library: "openssl",
+			os:      true,
+		},
+		{
+			in: "pkg:RPM/fedora/curl@8.2.1-1.fc39?repository_url=https%3A%2F%2Fmirror.example.com%2Ffedora",
+			want: PackageURL{Type: "rpm", Namespace: "fedora", Name: "curl", Version: "8.2.1-1.fc39",
+				Qualifiers: map[string]string{"repository_url": "https://mirror.example.com/fedora"}},
+			library: "curl",
+			os:      true,
+		},
+		{
+			in:      "pkg:pypi/django@4.2%2Blocal#/src/",
+			want:    PackageURL{Type: "pypi", Name: "django", Version: "4.2+local", Subpath: "src"},
+			library: "django",
+		},
+		{
+			in:      "pkg://generic/openssl",
+			want:    PackageURL{Type: "generic", Name: "openssl"},
+			library: "openssl",
+		},
+		{
+			in:      "pkg:nuget/a%2Fb/Newtonsoft.Json@13.0.1",
+			want:    PackageURL{Type: "nuget", Namespace: "a/b", Name: "Newtonsoft.Json", Version: "13.0.1"},
+			library: "Newtonsoft.Json",
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParsePURL(tt.in)
+		if err != nil {
+			t.Errorf("ParsePURL(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePURL(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if name := got.LibraryName(); name != tt.library {
+			t.Errorf("ParsePURL(%q).LibraryName() = %q, want %q", tt.in, name, tt.library)
+		}
+		if got.IsOSPackage() != tt.os {
+			t.Errorf("ParsePURL(%q).IsOSPackage() = %v, want %v", tt.in, got.IsOSPackage(), tt.os)
+		}
+	}
+}
+
+func TestParsePURLErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"maven/org.apache.logging.log4j/log4j-core@2.14.1",
+		"pkg:",
+		"pkg:maven",
+		"pkg:/log4j-core@2.14.1",
+		"pkg:maven/@2.14.1",
+	} {
+		if p, err := ParsePURL(in); err == nil {
+			t.Errorf("ParsePURL(%q) = %+v, want an error", in, p)
+		}
+	}
+}
+
+func TestPURLStringRoundTrip(t *testing.T) {
+	for _, in := range []string{
+		"pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1",
+		"pkg:npm/%40babel/core@7.23.0",
+		"pkg:golang/golang.org/x/net@v0.17.0#http2/hpack",
+		"pkg:deb/debian/openssl@3.0.11-1~deb12u2?arch=amd64&distro=debian-12",
+		"pkg:pypi/django@4.2+local",
+		"pkg:generic/openssl",
+	} {
+		p, err := ParsePURL(in)
+		if err != nil {
+			t.Fatalf("ParsePURL(%q): %v", in, err)
+		}
+		again, err := ParsePURL(p.String())
+		if err != nil {
+			t.Fatalf("ParsePURL(%q): %v", p.String(), err)
+		}
+		if !reflect.DeepEqual(again, p) {
+			t.Errorf("%q formats as %q, which parses as %+v, want %+v", in, p.String(), again, p)
+		}
+	}
+
+	// Qualifiers are sorted and @ in names is escaped
+	p := PackageURL{Type: "npm", Namespace: "@babel", Name: "core", Version: "7.23.0", Qualifiers: map[string]string{"z": "1", "a": "x/y"}}
+	if got, want := p.String(), "pkg:npm/%40babel/core@7.23.0?a=x%2Fy&z=1"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
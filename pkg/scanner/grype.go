@@ -0,0 +1,125 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// GrypeScanner adapts the JSON output of `grype dir:<path>`.
+type GrypeScanner struct {
+	BinaryPath string
+	ReportPath string
+}
+
+type grypeReport struct {
+	Matches []grypeMatch `json:"matches"`
+}
+
+type grypeMatch struct {
+	Vulnerability struct {
+		ID          string   `json:"id"`
+		DataSource  string   `json:"dataSource"`
+		Severity    string   `json:"severity"`
+		Description string   `json:"description"`
+		URLs        []string `json:"urls"`
+		Fix         struct {
+			Versions []string `json:"versions"`
+			State    string   `json:"state"`
+		} `json:"fix"`
+		Cvss []struct {
+			Metrics struct {
+				BaseScore           float64 `json:"baseScore"`
+				ExploitabilityScore float64 `json:"exploitabilityScore"`
+			} `json:"metrics"`
+		} `json:"cvss"`
+		Epss []struct {
+			Epss       float64 `json:"epss"`
+			Percentile float64 `json:"percentile"`
+		} `json:"epss"`
+		KnownExploited []struct {
+			DateAdded *wizcli.Date `json:"dateAdded"`
+			DueDate   *wizcli.Date `json:"dueDate"`
+		} `json:"knownExploited"`
+	} `json:"vulnerability"`
+	Artifact struct {
+		Name      string `json:"name"`
+		Version   string `json:"version"`
+		Type      string `json:"type"`
+		Locations []struct {
+			Path string `json:"path"`
+		} `json:"locations"`
+	} `json:"artifact"`
+}
+
+// Grype artifact types that come from the OS package manager.
+var grypeOsTypes = map[string]bool{
+	"deb":     true,
+	"rpm":     true,
+	"apk":     true,
+	"alpm":    true,
+	"portage": true,
+}
+
+func (s *GrypeScanner) Name() string {
+	return "grype"
+}
+
+// Scan runs `grype dir:<path>` against path, or reads ReportPath when it is set.
+func (s *GrypeScanner) Scan(path string) (*wizcli.AggregatedScanResults, error) {
+	data, err := readReport(s.ReportPath, s.BinaryPath, "dir:"+path, "--quiet", "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+	return parseGrypeReport(data)
+}
+
+func parseGrypeReport(data []byte) (*wizcli.AggregatedScanResults, error) {
+	var report grypeReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse grype report: %w", err)
+	}
+
+	c := newCollector()
+	for _, m := range report.Matches {
+		vuln := wizcli.Vulnerability{
+			Name:        m.Vulnerability.ID,
+			Severity:    m.Vulnerability.Severity,
+			Source:      m.Vulnerability.DataSource,
+			Description: m.Vulnerability.Description,
+		}
+		if m.Vulnerability.Fix.State == "fixed" && len(m.Vulnerability.Fix.Versions) > 0 {
+			vuln.FixedVersion = m.Vulnerability.Fix.Versions[0]
+		}
+		for _, cvss := range m.Vulnerability.Cvss {
+			if cvss.Metrics.BaseScore > vuln.Score {
+				vuln.Score = cvss.Metrics.BaseScore
+				vuln.ExploitabilityScore = cvss.Metrics.ExploitabilityScore
+			}
+		}
+		if len(m.Vulnerability.Epss) > 0 {
+			epss := m.Vulnerability.Epss[0]
+			vuln.EpssProbability = &epss.Epss
+			vuln.EpssPercentile = &epss.Percentile
+		}
+		if len(m.Vulnerability.KnownExploited) > 0 {
+			kev := m.Vulnerability.KnownExploited[0]
+			vuln.HasCisaKevExploit = true
+			vuln.CisaKevReleaseDate = kev.DateAdded
+			vuln.CisaKevDueDate = kev.DueDate
+		}
+
+		if grypeOsTypes[m.Artifact.Type] {
+			c.addOsPackage(m.Artifact.Name, m.Artifact.Version, vuln)
+			continue
+		}
+		path := ""
+		if len(m.Artifact.Locations) > 0 {
+			path = m.Artifact.Locations[0].Path
+		}
+		c.addLibrary(m.Artifact.Name, m.Artifact.Version, rootedPath(path), vuln)
+	}
+
+	return &c.results, nil
+}
@@ -0,0 +1,20 @@
+package scanner
+
+import (
+	"github.com/jtb75/wiz-scan/pkg/sbom"
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// SBOMScanner reads CycloneDX and SPDX documents produced at build time instead of scanning the disk.
+type SBOMScanner struct {
+	Paths []string
+}
+
+func (s *SBOMScanner) Name() string {
+	return "sbom"
+}
+
+// Scan ignores path since the SBOM documents already describe the host.
+func (s *SBOMScanner) Scan(path string) (*wizcli.AggregatedScanResults, error) {
+	return sbom.Load(s.Paths)
+}
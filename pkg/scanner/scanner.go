@@ -0,0 +1,124 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// Scanner scans a path on the local host and returns results normalized into the
+// wizcli result types consumed by CompareVulnerabilities.
+type Scanner interface {
+	// Name returns the engine name used in logs.
+	Name() string
+	// Scan scans the given path. Paths in the results are relative to it.
+	Scan(path string) (*wizcli.AggregatedScanResults, error)
+}
+
+// New returns the Scanner for the named engine. binaryPath overrides the engine
+// executable and reportPath, when set, makes the adapter read an existing JSON
+// report instead of running the engine; for sbom it is a comma separated list of
+// CycloneDX/SPDX documents. The wizcli binary is always downloaded,
+// so its BinaryPath is filled in once wizcli is authenticated.
+func New(engine, binaryPath, reportPath string) (Scanner, error) {
+	switch strings.ToLower(engine) {
+	case "", "wizcli":
+		if reportPath != "" {
+			return nil, fmt.Errorf("reading an existing report is not supported for wizcli")
+		}
+		return &WizCLIScanner{}, nil
+	case "trivy":
+		if binaryPath == "" {
+			binaryPath = "trivy"
+		}
+		return &TrivyScanner{BinaryPath: binaryPath, ReportPath: reportPath}, nil
+	case "grype":
+		if binaryPath == "" {
+			binaryPath = "grype"
+		}
+		return &GrypeScanner{BinaryPath: binaryPath, ReportPath: reportPath}, nil
+	case "sbom":
+		if reportPath == "" {
+			return nil, fmt.Errorf("the sbom scanner requires at least one SBOM file")
+		}
+		return &SBOMScanner{Paths: strings.Split(reportPath, ",")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported scanner %q (supported: wizcli, trivy, grype, sbom)", engine)
+	}
+}
+
+// readReport returns the JSON report either from reportPath or by running the engine.
+func readReport(reportPath, binaryPath string, args ...string) ([]byte, error) {
+	if reportPath != "" {
+		data, err := os.ReadFile(reportPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report %s: %w", reportPath, err)
+		}
+		return data, nil
+	}
+
+	cmd := exec.Command(binaryPath, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %v - Stderr: %s", binaryPath, err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// rootedPath makes an engine-relative path look like the wizcli paths, which start at the scan root.
+func rootedPath(path string) string {
+	if path == "" || strings.HasPrefix(path, "/") {
+		return path
+	}
+	return "/" + path
+}
+
+// collector groups per-vulnerability records from other engines into the per-component wizcli layout.
+type collector struct {
+	results   wizcli.AggregatedScanResults
+	libraries map[string]int
+	packages  map[string]int
+}
+
+func newCollector() *collector {
+	return &collector{
+		libraries: make(map[string]int),
+		packages:  make(map[string]int),
+	}
+}
+
+func (c *collector) addLibrary(name, version, path string, vuln wizcli.Vulnerability) {
+	key := name + "\x00" + version + "\x00" + path
+	i, ok := c.libraries[key]
+	if !ok {
+		i = len(c.results.Libraries)
+		c.libraries[key] = i
+		c.results.Libraries = append(c.results.Libraries, wizcli.Library{
+			Name:            name,
+			Version:         version,
+			Path:            path,
+			DetectionMethod: wizcli.DetectionMethodLibrary,
+		})
+	}
+	c.results.Libraries[i].Vulnerabilities = append(c.results.Libraries[i].Vulnerabilities, vuln)
+}
+
+func (c *collector) addOsPackage(name, version string, vuln wizcli.Vulnerability) {
+	key := name + "\x00" + version
+	i, ok := c.packages[key]
+	if !ok {
+		i = len(c.results.OsPackages)
+		c.packages[key] = i
+		c.results.OsPackages = append(c.results.OsPackages, wizcli.OsPackage{
+			Name:            name,
+			Version:         version,
+			DetectionMethod: wizcli.DetectionMethodPackage,
+		})
+	}
+	c.results.OsPackages[i].Vulnerabilities = append(c.results.OsPackages[i].Vulnerabilities, vuln)
+}
@@ -0,0 +1,171 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// flatVuln is one vulnerability of a component in scan results, for comparing them.
+type flatVuln struct {
+	kind, pkg, version, path, cve, severity, fixed string
+	score                                          float64
+}
+
+func flatten(t *testing.T, results *wizcli.AggregatedScanResults) map[string]flatVuln {
+	flat := make(map[string]flatVuln)
+	for _, lib := range results.Libraries {
+		if lib.DetectionMethod != wizcli.DetectionMethodLibrary {
+			t.Errorf("library %s has detection method %s", lib.Name, lib.DetectionMethod)
+		}
+		for _, v := range lib.Vulnerabilities {
+			flat[v.Name+" "+lib.Name] = flatVuln{"library", lib.Name, lib.Version, lib.Path, v.Name, v.Severity, v.FixedVersion, v.Score}
+		}
+	}
+	for _, pkg := range results.OsPackages {
+		if pkg.DetectionMethod != wizcli.DetectionMethodPackage {
+			t.Errorf("OS package %s has detection method %s", pkg.Name, pkg.DetectionMethod)
+		}
+		for _, v := range pkg.Vulnerabilities {
+			flat[v.Name+" "+pkg.Name] = flatVuln{"os", pkg.Name, pkg.Version, "", v.Name, v.Severity, v.FixedVersion, v.Score}
+		}
+	}
+	return flat
+}
+
+func checkResults(t *testing.T, results *wizcli.AggregatedScanResults, want []flatVuln) {
+	t.Helper()
+	got := flatten(t, results)
+	if len(got) != len(want) {
+		t.Errorf("got %d vulnerabilities, want %d: %+v", len(got), len(want), got)
+	}
+	for _, w := range want {
+		if g, ok := got[w.cve+" "+w.pkg]; !ok {
+			t.Errorf("%s in %s is missing", w.cve, w.pkg)
+		} else if g != w {
+			t.Errorf("%s in %s is\n  %+v, want\n  %+v", w.cve, w.pkg, g, w)
+		}
+	}
+}
+
+func TestTrivyReport(t *testing.T) {
+	sc, err := New("Trivy", "", "testdata/trivy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := sc.Scan("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, results, []flatVuln{
+		{"os", "openssl", "3.0.11-1~deb12u2", "", "CVE-2024-0727", "MEDIUM", "3.0.13-1~deb12u1", 5.9},
+		{"os", "openssl", "3.0.11-1~deb12u2", "", "CVE-2023-5678", "LOW", "", 0},
+		// Without a package path the target, the lock file, is the path
+		{"library", "lodash", "4.17.20", "/opt/app/package-lock.json", "CVE-2021-23337", "HIGH", "4.17.21", 7.2},
+		{"library", "org.apache.logging.log4j:log4j-core", "2.14.1", "/opt/app/lib/log4j-core-2.14.1.jar", "CVE-2021-44228", "CRITICAL", "2.15.0", 10},
+	})
+	if len(results.OsPackages) != 1 {
+		t.Errorf("openssl is %d packages, want one with both vulnerabilities", len(results.OsPackages))
+	}
+	var published *wizcli.Date
+	for _, v := range results.OsPackages[0].Vulnerabilities {
+		if v.Name == "CVE-2024-0727" {
+			published = v.PublishDate
+		}
+	}
+	if published == nil || published.Year() != 2024 {
+		t.Errorf("published date is %v", published)
+	}
+}
+
+func TestTrivyReportSchemaVersion(t *testing.T) {
+	if _, err := parseTrivyReport([]byte(`{"SchemaVersion": 1, "Results": []}`)); err == nil {
+		t.Error("a schema version 1 report was accepted")
+	}
+	if _, err := parseTrivyReport([]byte(`not json`)); err == nil {
+		t.Error("a report that isn't JSON was accepted")
+	}
+}
+
+func TestGrypeReport(t *testing.T) {
+	sc, err := New("grype", "", "testdata/grype.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := sc.Scan("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, results, []flatVuln{
+		{"os", "openssl", "3.0.11-1~deb12u2", "", "CVE-2024-0727", "Medium", "3.0.13-1~deb12u1", 5.5},
+		{"os", "openssl", "3.0.11-1~deb12u2", "", "CVE-2023-5678", "Low", "", 0},
+		{"library", "log4j-core", "2.14.1", "/opt/app/lib/log4j-core-2.14.1.jar", "CVE-2021-44228", "Critical", "2.15.0", 10},
+		// A fix the maintainers won't ship isn't a fixed version
+		{"library", "lodash", "4.17.20", "/srv/web/node_modules/lodash/package.json", "GHSA-35jh-r3h4-6jhm", "High", "", 0},
+	})
+
+	var log4j wizcli.Vulnerability
+	for _, lib := range results.Libraries {
+		if lib.Name == "log4j-core" {
+			log4j = lib.Vulnerabilities[0]
+		}
+	}
+	if log4j.ExploitabilityScore != 3.9 || log4j.EpssProbability == nil || *log4j.EpssProbability != 0.97 || *log4j.EpssPercentile != 0.99 {
+		t.Errorf("log4j scores are %+v", log4j)
+	}
+	if !log4j.HasCisaKevExploit || log4j.CisaKevReleaseDate == nil || log4j.CisaKevDueDate == nil || log4j.CisaKevDueDate.Day() != 24 {
+		t.Errorf("log4j KEV is %v, %v, %v", log4j.HasCisaKevExploit, log4j.CisaKevReleaseDate, log4j.CisaKevDueDate)
+	}
+}
+
+func TestSBOMScanner(t *testing.T) {
+	if _, err := New("sbom", "", ""); err == nil {
+		t.Error("the sbom scanner was created without a document")
+	}
+	// An SPDX inventory with the vulnerabilities in a separate CycloneDX VEX document
+	sc, err := New("sbom", "", "testdata/inventory.spdx.json,testdata/vex.cdx.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := sc.Scan("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, results, []flatVuln{
+		{"os", "openssl", "3.0.11-1~deb12u2", "", "CVE-2024-0727", "medium", "3.0.13-1~deb12u1", 5.5},
+		{"library", "org.apache.logging.log4j:log4j-core", "2.14.1", "/opt/app/lib/log4j-core-2.14.1.jar", "CVE-2021-44228", "critical", "2.15.0", 10},
+	})
+	// lodash is in the inventory without vulnerabilities, the VEX says CVE-2021-23337 doesn't affect it
+	found := false
+	for _, lib := range results.Libraries {
+		if lib.Name == "lodash" {
+			found = true
+			if len(lib.Vulnerabilities) != 0 {
+				t.Errorf("lodash has %d vulnerabilities, the VEX says it isn't affected", len(lib.Vulnerabilities))
+			}
+		}
+	}
+	if !found {
+		t.Error("lodash is missing")
+	}
+
+	sc, err = New("sbom", "", "testdata/missing.cdx.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.Scan("/"); err == nil {
+		t.Error("a missing SBOM document was read")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("wizcli", "", "report.json"); err == nil {
+		t.Error("wizcli was created to read a report")
+	}
+	if _, err := New("snyk", "", ""); err == nil {
+		t.Error("an unsupported scanner was created")
+	}
+	if sc, err := New("", "", ""); err != nil || sc.Name() != "wizcli" {
+		t.Errorf("the default scanner is %v, %v", sc, err)
+	}
+}
@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// TrivyScanner adapts the JSON output of `trivy fs`.
+type TrivyScanner struct {
+	BinaryPath string
+	ReportPath string
+}
+
+type trivyReport struct {
+	SchemaVersion int           `json:"SchemaVersion"`
+	Results       []trivyResult `json:"Results"`
+}
+
+type trivyResult struct {
+	Target          string               `json:"Target"`
+	Class           string               `json:"Class"`
+	Type            string               `json:"Type"`
+	Vulnerabilities []trivyVulnerability `json:"Vulnerabilities"`
+}
+
+type trivyVulnerability struct {
+	VulnerabilityID  string               `json:"VulnerabilityID"`
+	PkgName          string               `json:"PkgName"`
+	PkgPath          string               `json:"PkgPath"`
+	InstalledVersion string               `json:"InstalledVersion"`
+	FixedVersion     string               `json:"FixedVersion"`
+	PrimaryURL       string               `json:"PrimaryURL"`
+	Description      string               `json:"Description"`
+	Severity         string               `json:"Severity"`
+	CVSS             map[string]trivyCVSS `json:"CVSS"`
+	PublishedDate    *wizcli.Date         `json:"PublishedDate"`
+}
+
+type trivyCVSS struct {
+	V2Score float64 `json:"V2Score"`
+	V3Score float64 `json:"V3Score"`
+}
+
+func (s *TrivyScanner) Name() string {
+	return "trivy"
+}
+
+// Scan runs `trivy fs` against path, or reads ReportPath when it is set.
+func (s *TrivyScanner) Scan(path string) (*wizcli.AggregatedScanResults, error) {
+	data, err := readReport(s.ReportPath, s.BinaryPath, "fs", "--quiet", "--format", "json", "--scanners", "vuln", path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTrivyReport(data)
+}
+
+func parseTrivyReport(data []byte) (*wizcli.AggregatedScanResults, error) {
+	var report trivyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse trivy report: %w", err)
+	}
+	if report.SchemaVersion != 2 {
+		return nil, fmt.Errorf("unsupported trivy report schema version %d", report.SchemaVersion)
+	}
+
+	c := newCollector()
+	for _, result := range report.Results {
+		for _, tv := range result.Vulnerabilities {
+			vuln := wizcli.Vulnerability{
+				Name:         tv.VulnerabilityID,
+				Severity:     tv.Severity,
+				FixedVersion: tv.FixedVersion,
+				Source:       tv.PrimaryURL,
+				Description:  tv.Description,
+				PublishDate:  tv.PublishedDate,
+			}
+			for _, cvss := range tv.CVSS {
+				if cvss.V3Score > vuln.Score {
+					vuln.Score = cvss.V3Score
+				}
+			}
+
+			if result.Class == "os-pkgs" {
+				c.addOsPackage(tv.PkgName, tv.InstalledVersion, vuln)
+				continue
+			}
+			path := tv.PkgPath
+			if path == "" {
+				path = result.Target
+			}
+			c.addLibrary(tv.PkgName, tv.InstalledVersion, rootedPath(path), vuln)
+		}
+	}
+
+	return &c.results, nil
+}
@@ -0,0 +1,29 @@
+package scanner
+
+import (
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// WizCLIScanner is the default Scanner and runs an already authenticated wizcli binary.
+type WizCLIScanner struct {
+	BinaryPath string
+}
+
+func (s *WizCLIScanner) Name() string {
+	return "wizcli"
+}
+
+// Scan runs `wizcli dir scan` against path. A policy failure still returns the results.
+func (s *WizCLIScanner) Scan(path string) (*wizcli.AggregatedScanResults, error) {
+	scanResult, _, err := wizcli.ScanDirectory(s.BinaryPath, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wizcli.AggregatedScanResults{
+		OsPackages:   scanResult.Result.OsPackages,
+		Libraries:    scanResult.Result.Libraries,
+		Applications: scanResult.Result.Applications,
+		Cpes:         scanResult.Result.Cpes,
+	}, nil
+}
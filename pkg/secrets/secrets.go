@@ -0,0 +1,108 @@
+// Package secrets resolves references to secrets kept outside the wiz-scan configuration.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ExecTimeout bounds how long an exec: reference may take.
+var ExecTimeout = 30 * time.Second
+
+// IsReference reports whether value points to a secret rather than being one.
+func IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, ":")
+	if !ok {
+		return false
+	}
+	switch scheme {
+	case "env", "exec", "vault":
+		return true
+	case "file":
+		return strings.HasPrefix(value, "file://")
+	}
+	return false
+}
+
+// Resolve returns the secret value points to. Values that aren't references are returned
+// as they are. Supported references are
+//
+//	file:///run/secrets/wiz             the content of a file
+//	env:NAME                            an environment variable
+//	exec:/usr/local/bin/get-secret arg  the output of a command, run without a shell
+//	vault:secret/data/wiz#clientSecret  a field of a HashiCorp Vault KV secret
+func Resolve(value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	scheme, rest, _ := strings.Cut(value, ":")
+
+	var secret string
+	var err error
+	switch scheme {
+	case "file":
+		secret, err = resolveFile(value)
+	case "env":
+		var ok bool
+		if secret, ok = os.LookupEnv(rest); !ok {
+			err = fmt.Errorf("environment variable %s is not set", rest)
+		}
+	case "exec":
+		secret, err = resolveExec(rest)
+	case "vault":
+		secret, err = resolveVault(rest)
+	}
+	if err != nil {
+		return "", err
+	}
+	if secret = strings.TrimSpace(secret); secret == "" {
+		return "", fmt.Errorf("%s resolved to an empty value", scheme)
+	}
+	return secret, nil
+}
+
+func resolveFile(ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid file reference: %w", err)
+	}
+	path := u.Path
+	// file:///C:/secrets/wiz names C:/secrets/wiz on Windows
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return string(data), nil
+}
+
+func resolveExec(command string) (string, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "", errors.New("exec reference without a command")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ExecTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s failed: %w: %s", fields[0], err, msg)
+		}
+		return "", fmt.Errorf("%s failed: %w", fields[0], err)
+	}
+	return stdout.String(), nil
+}
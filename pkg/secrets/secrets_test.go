@@ -0,0 +1,92 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsReference(t *testing.T) {
+	tests := map[string]bool{
+		"file:///run/secrets/wiz":        true,
+		"file:relative":                  false,
+		"env:WIZ_SECRET":                 true,
+		"exec:/usr/local/bin/get-secret": true,
+		"vault:secret/data/wiz#secret":   true,
+		"plain-secret":                   false,
+		"https://example.com":            false,
+		"token:abc":                      false,
+	}
+	for value, want := range tests {
+		if got := IsReference(value); got != want {
+			t.Errorf("IsReference(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestResolveFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ref := "file://" + filepath.ToSlash(path)
+	if runtime.GOOS == "windows" {
+		ref = "file:///" + filepath.ToSlash(path)
+	}
+	if got, err := Resolve(ref); err != nil || got != "s3cret" {
+		t.Errorf("Resolve(%q) = %q, %v", ref, got, err)
+	}
+
+	if _, err := Resolve("file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "missing"))); err == nil {
+		t.Error("a missing file resolved")
+	}
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, []byte(" \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve("file://" + filepath.ToSlash(empty)); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("an empty file resolved: %v", err)
+	}
+}
+
+func TestResolveEnv(t *testing.T) {
+	t.Setenv("WIZ_SCAN_TEST_SECRET", "  s3cret ")
+	if got, err := Resolve("env:WIZ_SCAN_TEST_SECRET"); err != nil || got != "s3cret" {
+		t.Errorf("Resolve(env) = %q, %v", got, err)
+	}
+	if _, err := Resolve("env:WIZ_SCAN_TEST_UNSET"); err == nil {
+		t.Error("an unset variable resolved")
+	}
+	if got, err := Resolve("not-a-reference"); err != nil || got != "not-a-reference" {
+		t.Errorf("a plain value came back as %q, %v", got, err)
+	}
+}
+
+func TestResolveExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX commands")
+	}
+	if got, err := Resolve("exec:echo s3cret"); err != nil || got != "s3cret" {
+		t.Errorf("Resolve(exec) = %q, %v", got, err)
+	}
+	if _, err := Resolve("exec:false"); err == nil {
+		t.Error("a failing command resolved")
+	}
+	if _, err := Resolve("exec:   "); err == nil {
+		t.Error("an empty command resolved")
+	}
+
+	timeout := ExecTimeout
+	ExecTimeout = 100 * time.Millisecond
+	defer func() { ExecTimeout = timeout }()
+	start := time.Now()
+	if _, err := Resolve("exec:sleep 5"); err == nil {
+		t.Error("a command beyond the timeout resolved")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("the command ran for %s despite the timeout", elapsed)
+	}
+}
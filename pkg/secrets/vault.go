@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var vaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// resolveVault reads a field of a Vault KV secret. The reference is the API path of the
+// secret and the field, such as secret/data/wiz#clientSecret for KV version 2 or
+// kv/wiz#clientSecret for version 1. The server and token come from VAULT_ADDR and
+// VAULT_TOKEN (or ~/.vault-token), and VAULT_NAMESPACE when set.
+func resolveVault(ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("vault reference %q must be path#field", ref)
+	}
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return "", errors.New("VAULT_ADDR is not set")
+	}
+	token, err := vaultToken()
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequest(http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid vault request: %w", err)
+	}
+	request.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		request.Header.Set("X-Vault-Namespace", namespace)
+	}
+	response, err := vaultClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to query vault: %w", err)
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []string               `json:"errors"`
+	}
+	decodeErr := json.NewDecoder(response.Body).Decode(&body)
+	// Error responses from a proxy in front of Vault may not be JSON, their status still is the error
+	if response.StatusCode != http.StatusOK {
+		if len(body.Errors) > 0 {
+			return "", fmt.Errorf("vault returned status %d for %s: %s", response.StatusCode, path, strings.Join(body.Errors, "; "))
+		}
+		return "", fmt.Errorf("vault returned status %d for %s", response.StatusCode, path)
+	}
+	if decodeErr != nil {
+		return "", fmt.Errorf("failed to decode vault response: %w", decodeErr)
+	}
+
+	data := body.Data
+	// KV version 2 nests the secret under data, next to its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, v2 := data["metadata"]; v2 {
+			data = nested
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %q", path, field)
+	}
+	secret, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s field %q is not a string", path, field)
+	}
+	return secret, nil
+}
+
+func vaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err == nil {
+		if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+			if token := strings.TrimSpace(string(data)); token != "" {
+				return token, nil
+			}
+		}
+	}
+	return "", errors.New("no vault token in VAULT_TOKEN or ~/.vault-token")
+}
@@ -0,0 +1,128 @@
+package secrets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// vaultServer serves body with status for every request, checking the token and namespace.
+func vaultServer(t *testing.T, status int, body string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			t.Errorf("token %q", r.Header.Get("X-Vault-Token"))
+		}
+		if r.Header.Get("X-Vault-Namespace") != "team" {
+			t.Errorf("namespace %q", r.Header.Get("X-Vault-Namespace"))
+		}
+		if r.URL.Path != "/v1/secret/data/wiz" {
+			t.Errorf("path %s", r.URL.Path)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL+"/")
+	t.Setenv("VAULT_TOKEN", "test-token")
+	t.Setenv("VAULT_NAMESPACE", "team")
+}
+
+func TestResolveVault(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "KV version 2",
+			status: http.StatusOK,
+			body:   `{"data":{"data":{"clientSecret":"v2-secret"},"metadata":{"version":3}}}`,
+			ref:    "vault:secret/data/wiz#clientSecret",
+			want:   "v2-secret",
+		},
+		{
+			name:   "KV version 1",
+			status: http.StatusOK,
+			body:   `{"data":{"clientSecret":"v1-secret"}}`,
+			ref:    "vault:secret/data/wiz#clientSecret",
+			want:   "v1-secret",
+		},
+		{
+			name:   "KV version 1 secret with a data field",
+			status: http.StatusOK,
+			body:   `{"data":{"data":{"nested":"x"},"clientSecret":"v1-secret"}}`,
+			ref:    "vault:secret/data/wiz#clientSecret",
+			want:   "v1-secret",
+		},
+		{
+			name:    "missing field",
+			status:  http.StatusOK,
+			body:    `{"data":{"data":{"clientId":"id"},"metadata":{}}}`,
+			ref:     "vault:secret/data/wiz#clientSecret",
+			wantErr: `no field "clientSecret"`,
+		},
+		{
+			name:    "field that isn't a string",
+			status:  http.StatusOK,
+			body:    `{"data":{"data":{"clientSecret":42},"metadata":{}}}`,
+			ref:     "vault:secret/data/wiz#clientSecret",
+			wantErr: "is not a string",
+		},
+		{
+			name:    "permission denied",
+			status:  http.StatusForbidden,
+			body:    `{"errors":["permission denied"]}`,
+			ref:     "vault:secret/data/wiz#clientSecret",
+			wantErr: "status 403 for secret/data/wiz: permission denied",
+		},
+		{
+			name:    "not found",
+			status:  http.StatusNotFound,
+			body:    `{"errors":[]}`,
+			ref:     "vault:secret/data/wiz#clientSecret",
+			wantErr: "status 404",
+		},
+		{
+			name:    "error page that isn't JSON",
+			status:  http.StatusBadGateway,
+			body:    `<html>Bad Gateway</html>`,
+			ref:     "vault:secret/data/wiz#clientSecret",
+			wantErr: "status 502",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vaultServer(t, tt.status, tt.body)
+			got, err := Resolve(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %q, %v, want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveVaultReference(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "http://127.0.0.1:1")
+	t.Setenv("VAULT_TOKEN", "test-token")
+	for _, ref := range []string{"vault:secret/data/wiz", "vault:#field", "vault:secret/data/wiz#"} {
+		if _, err := Resolve(ref); err == nil || !strings.Contains(err.Error(), "path#field") {
+			t.Errorf("Resolve(%q) = %v, want a path#field error", ref, err)
+		}
+	}
+
+	t.Setenv("VAULT_ADDR", "")
+	if _, err := Resolve("vault:secret/data/wiz#clientSecret"); err == nil || !strings.Contains(err.Error(), "VAULT_ADDR") {
+		t.Errorf("resolving without VAULT_ADDR: %v", err)
+	}
+}
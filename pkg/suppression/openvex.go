@@ -0,0 +1,146 @@
+package suppression
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jtb75/wiz-scan/pkg/sbom"
+)
+
+// OpenVEX statuses that mean the vulnerability needs no action.
+var vexSuppressingStatuses = map[string]bool{
+	"not_affected": true,
+	"fixed":        true,
+}
+
+type vexDocument struct {
+	Statements []vexStatement `json:"statements"`
+}
+
+type vexStatement struct {
+	Vulnerability   json.RawMessage   `json:"vulnerability"`
+	Products        []json.RawMessage `json:"products"`
+	Status          string            `json:"status"`
+	Justification   string            `json:"justification"`
+	ImpactStatement string            `json:"impact_statement"`
+	StatusNotes     string            `json:"status_notes"`
+}
+
+// vexProduct is a product of OpenVEX 0.2, older versions list plain identifiers.
+type vexProduct struct {
+	ID            string            `json:"@id"`
+	Identifiers   map[string]string `json:"identifiers"`
+	Subcomponents []struct {
+		ID          string            `json:"@id"`
+		Identifiers map[string]string `json:"identifiers"`
+	} `json:"subcomponents"`
+}
+
+// parseOpenVEX turns the not_affected and fixed statements of an OpenVEX document into entries.
+// Products identified by a package URL match on the package name and version, any other
+// identifier is used as package name.
+func parseOpenVEX(data []byte) ([]Entry, error) {
+	var doc vexDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for i, statement := range doc.Statements {
+		if !vexSuppressingStatuses[statement.Status] {
+			continue
+		}
+		cve, err := vexVulnerability(statement.Vulnerability)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		justification := statement.Status
+		for _, detail := range []string{statement.Justification, statement.ImpactStatement, statement.StatusNotes} {
+			if detail != "" {
+				justification += ": " + detail
+				break
+			}
+		}
+
+		products, err := vexProducts(statement.Products)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		if len(products) == 0 {
+			entries = append(entries, Entry{CVE: cve, Justification: justification})
+			continue
+		}
+		for _, product := range products {
+			entry := Entry{CVE: cve, Package: product, Justification: justification}
+			if purl, err := sbom.ParsePURL(product); err == nil {
+				entry.Package = purl.LibraryName()
+				entry.Version = purl.Version
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// vexVulnerability reads the vulnerability name, a string before OpenVEX 0.2 and an object since.
+func vexVulnerability(raw json.RawMessage) (string, error) {
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil && name != "" {
+		return name, nil
+	}
+	var vuln struct {
+		Name string `json:"name"`
+		ID   string `json:"@id"`
+	}
+	if err := json.Unmarshal(raw, &vuln); err != nil {
+		return "", fmt.Errorf("invalid vulnerability: %w", err)
+	}
+	if vuln.Name == "" {
+		vuln.Name = vuln.ID
+	}
+	if vuln.Name == "" {
+		return "", fmt.Errorf("vulnerability has no name")
+	}
+	return vuln.Name, nil
+}
+
+// vexProducts lists the package identifiers a statement applies to. Subcomponents narrow a
+// product such as an image down to the packages in it, so they are used when present.
+func vexProducts(raws []json.RawMessage) ([]string, error) {
+	var ids []string
+	for _, raw := range raws {
+		var id string
+		if err := json.Unmarshal(raw, &id); err == nil {
+			ids = append(ids, id)
+			continue
+		}
+		var product vexProduct
+		if err := json.Unmarshal(raw, &product); err != nil {
+			return nil, fmt.Errorf("invalid product: %w", err)
+		}
+		if len(product.Subcomponents) > 0 {
+			for _, sub := range product.Subcomponents {
+				ids = append(ids, vexIdentifier(sub.ID, sub.Identifiers))
+			}
+			continue
+		}
+		ids = append(ids, vexIdentifier(product.ID, product.Identifiers))
+	}
+
+	filtered := ids[:0]
+	for _, id := range ids {
+		if strings.TrimSpace(id) != "" {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered, nil
+}
+
+// vexIdentifier prefers the package URL of a product over its @id.
+func vexIdentifier(id string, identifiers map[string]string) string {
+	if purl := identifiers["purl"]; purl != "" {
+		return purl
+	}
+	return id
+}
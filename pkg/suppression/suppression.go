@@ -0,0 +1,269 @@
+package suppression
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jtb75/wiz-scan/pkg/ecosystem"
+	"github.com/jtb75/wiz-scan/pkg/glob"
+)
+
+// Entry is an accepted-risk decision for a vulnerability. Empty fields match anything,
+// Package and Path accept glob patterns and Version is either a version or comma separated
+// constraints such as ">=2.0, <2.17.1", compared by the rules of the package's ecosystem.
+type Entry struct {
+	CVE           string `yaml:"cve"`
+	Package       string `yaml:"package"`
+	Version       string `yaml:"version"`
+	Path          string `yaml:"path"`
+	Justification string `yaml:"justification"`
+	Expires       string `yaml:"expires"`
+
+	// Source is the file the entry was read from.
+	Source string `yaml:"-"`
+
+	expiresAt   time.Time
+	constraints []constraint
+}
+
+// constraint is one comparison of a version constraint list.
+type constraint struct {
+	op      string
+	version string
+}
+
+// file is the layout of a local suppression file.
+type file struct {
+	Suppressions []Entry `yaml:"suppressions"`
+}
+
+// List holds the suppressions read from local suppression files and OpenVEX documents.
+type List struct {
+	active  []Entry
+	expired []Entry
+}
+
+// Load reads suppression files and OpenVEX documents, telling them apart by content.
+// Entries whose expiry date has passed are kept aside and reported by Expired.
+func Load(paths []string) (*List, error) {
+	return load(paths, time.Now())
+}
+
+func load(paths []string, now time.Time) (*List, error) {
+	l := &List{}
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read suppression file: %w", err)
+		}
+
+		var entries []Entry
+		if isOpenVEX(data) {
+			entries, err = parseOpenVEX(data)
+		} else {
+			entries, err = parseFile(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse suppression file %s: %w", path, err)
+		}
+
+		for _, entry := range entries {
+			entry.Source = path
+			if entry.CVE == "" && entry.Package == "" && entry.Path == "" {
+				return nil, fmt.Errorf("suppression in %s needs at least a cve, package or path", path)
+			}
+			if entry.constraints, err = parseConstraints(entry.Version); err != nil {
+				return nil, fmt.Errorf("suppression of %s in %s: %w", entry.describe(), path, err)
+			}
+			if entry.Expires != "" {
+				expiresAt, err := parseExpiry(entry.Expires)
+				if err != nil {
+					return nil, fmt.Errorf("suppression of %s in %s: %w", entry.describe(), path, err)
+				}
+				entry.expiresAt = expiresAt
+				if !now.Before(expiresAt) {
+					l.expired = append(l.expired, entry)
+					continue
+				}
+			}
+			l.active = append(l.active, entry)
+		}
+	}
+	return l, nil
+}
+
+func parseFile(data []byte) ([]Entry, error) {
+	var f file
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&f); err != nil {
+		return nil, err
+	}
+	return f.Suppressions, nil
+}
+
+// parseExpiry accepts a date, which expires at the end of that day in UTC, or an RFC 3339 time.
+func parseExpiry(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.AddDate(0, 0, 1), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry date %q, expected YYYY-MM-DD", s)
+}
+
+// parseConstraints parses a version, which must be equal, or comma separated comparisons
+// using <, <=, >, >=, = and !=.
+func parseConstraints(s string) ([]constraint, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var constraints []constraint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		c := constraint{op: "="}
+		for _, op := range []string{"<=", ">=", "!=", "<", ">", "="} {
+			if strings.HasPrefix(part, op) {
+				c.op, part = op, strings.TrimSpace(part[len(op):])
+				break
+			}
+		}
+		if part == "" || strings.ContainsAny(part, "<>=! ") {
+			return nil, fmt.Errorf("invalid version constraint %q", s)
+		}
+		c.version = part
+		constraints = append(constraints, c)
+	}
+	return constraints, nil
+}
+
+// Len returns the number of active suppressions.
+func (l *List) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.active)
+}
+
+// Expired returns the suppressions whose expiry date has passed.
+func (l *List) Expired() []Entry {
+	if l == nil {
+		return nil
+	}
+	return l.expired
+}
+
+// Match returns the active suppression covering the vulnerability, or nil. When only an
+// expired suppression covers it, that one is returned as expired so callers can warn.
+// Versions are compared by the rules of eco.
+func (l *List) Match(cve, pkg, version, path string, eco ecosystem.Ecosystem) (active *Entry, expired *Entry) {
+	if l == nil {
+		return nil, nil
+	}
+	for i := range l.active {
+		if l.active[i].matches(cve, pkg, version, path, eco) {
+			return &l.active[i], nil
+		}
+	}
+	for i := range l.expired {
+		if l.expired[i].matches(cve, pkg, version, path, eco) {
+			return nil, &l.expired[i]
+		}
+	}
+	return nil, nil
+}
+
+func (e *Entry) matches(cve, pkg, version, path string, eco ecosystem.Ecosystem) bool {
+	if e.CVE != "" && !strings.EqualFold(e.CVE, cve) {
+		return false
+	}
+	if e.Package != "" && !glob.Match(e.Package, pkg, false) {
+		return false
+	}
+	if len(e.constraints) > 0 && !e.matchesVersion(version, eco) {
+		return false
+	}
+	if e.Path != "" && !glob.Match(e.Path, path, true) {
+		return false
+	}
+	return true
+}
+
+func (e *Entry) matchesVersion(version string, eco ecosystem.Ecosystem) bool {
+	if strings.TrimSpace(version) == "" {
+		return false
+	}
+	for _, c := range e.constraints {
+		var ok bool
+		switch c.op {
+		case "=":
+			ok = ecosystem.Equal(eco, version, c.version)
+		case "!=":
+			ok = !ecosystem.Equal(eco, version, c.version)
+		case "<":
+			ok = ecosystem.Compare(eco, version, c.version) < 0
+		case "<=":
+			ok = ecosystem.Compare(eco, version, c.version) <= 0
+		case ">":
+			ok = ecosystem.Compare(eco, version, c.version) > 0
+		case ">=":
+			ok = ecosystem.Compare(eco, version, c.version) >= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ExpiresAt returns when the suppression expires, the zero time if it doesn't.
+func (e *Entry) ExpiresAt() time.Time {
+	return e.expiresAt
+}
+
+// describe names the entry in log messages and report reasons.
+func (e *Entry) describe() string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{e.CVE, e.Package, e.Path} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Reason explains the suppression in a verdict.
+func (e *Entry) Reason() string {
+	justification := e.Justification
+	if justification == "" {
+		justification = "no justification given"
+	}
+	return fmt.Sprintf("suppressed by %s: %s", e.Source, justification)
+}
+
+// String describes the entry for warnings about expired suppressions.
+func (e *Entry) String() string {
+	return fmt.Sprintf("%s (%s, expired %s)", e.describe(), e.Source, e.Expires)
+}
+
+// isOpenVEX reports whether data is an OpenVEX JSON document.
+func isOpenVEX(data []byte) bool {
+	var doc struct {
+		Context string `json:"@context"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	return strings.Contains(doc.Context, "openvex")
+}
@@ -0,0 +1,75 @@
+package suppression
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jtb75/wiz-scan/pkg/ecosystem"
+)
+
+func TestMatchVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suppressions.yaml")
+	err := os.WriteFile(path, []byte(`suppressions:
+  - cve: CVE-2021-44228
+    package: log4j-core
+    version: ">=2.0, <2.15.0"
+  - cve: CVE-2022-1
+    package: lodash
+    version: 4.17.20
+  - cve: CVE-2023-1
+    package: musl
+    version: "<1.2.4"
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := load([]string{path}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cve, pkg, version string
+		eco               ecosystem.Ecosystem
+		want              bool
+	}{
+		{"CVE-2021-44228", "log4j-core", "2.14.1", ecosystem.Maven, true},
+		{"CVE-2021-44228", "log4j-core", "2.0-beta9", ecosystem.Maven, false},
+		{"CVE-2021-44228", "log4j-core", "2.15.0", ecosystem.Maven, false},
+		{"CVE-2021-44228", "log4j-core", "2.9.1", ecosystem.Maven, true},
+		{"CVE-2021-44228", "log4j-core", "", ecosystem.Maven, false},
+		{"CVE-2022-1", "lodash", "v4.17.20", ecosystem.Npm, true},
+		{"CVE-2022-1", "lodash", "4.17.21", ecosystem.Npm, false},
+		{"CVE-2023-1", "musl", "1.2.4_rc1-r0", ecosystem.Alpine, true},
+		{"CVE-2023-1", "musl", "1.2.4-r0", ecosystem.Alpine, false},
+	}
+	for _, tt := range tests {
+		active, _ := list.Match(tt.cve, tt.pkg, tt.version, "", tt.eco)
+		if got := active != nil; got != tt.want {
+			t.Errorf("Match(%s, %s, %s) = %v, want %v", tt.cve, tt.pkg, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestParseConstraints(t *testing.T) {
+	for _, s := range []string{">=", "1.0 2.0", "=>1.0", ">1.0,"} {
+		if _, err := parseConstraints(s); err == nil {
+			t.Errorf("parseConstraints(%q) succeeded, want an error", s)
+		}
+	}
+	constraints, err := parseConstraints(" >= 1.0 , !=1.5,<2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []constraint{{">=", "1.0"}, {"!=", "1.5"}, {"<", "2"}}
+	if len(constraints) != len(want) {
+		t.Fatalf("got %v, want %v", constraints, want)
+	}
+	for i := range want {
+		if constraints[i] != want[i] {
+			t.Errorf("constraint %d is %v, want %v", i, constraints[i], want[i])
+		}
+	}
+}
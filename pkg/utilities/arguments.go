@@ -1,13 +1,10 @@
 package utilities
 
 import (
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
 )
 
 type Arguments struct {
@@ -18,14 +15,67 @@ type Arguments struct {
 	ScanSubscriptionID string `json:"scanSubscriptionId"`
 	ScanCloudType      string `json:"scanCloudType"`
 	ScanProviderID     string `json:"scanProviderId"`
+	Proxy              string `json:"proxy"`
+	Scanner            string `json:"scanner"`
+	ScannerPath        string `json:"scannerPath"`
+	ScannerReport      string `json:"scannerReport"`
+	ScanPaths          string `json:"scanPaths"`
+	Exclude            string `json:"exclude"`
+	SBOMOutput         string `json:"sbomOutput"`
+	SBOMFormat         string `json:"sbomFormat"`
+	Report             string `json:"report"`
+	ReportFile         string `json:"reportFile"`
+	Policy             string `json:"policy"`
+	Suppressions       string `json:"suppressions"`
+	ChunkMaxFindings   int    `json:"chunkMaxFindings"`
+	ChunkMaxBytes      int    `json:"chunkMaxBytes"`
+	FindingsOutput     string `json:"findingsOutput"`
+	DryRun             bool   `json:"-"`
+	ScanResults        string `json:"-"`
+	KnownVulns         string `json:"-"`
+	Output             string `json:"-"`
+	History            bool   `json:"history"`
+	HistoryRuns        int    `json:"historyRuns"`
+	Schedule           string `json:"schedule"`
+	Jitter             string `json:"jitter"`
+	Retries            int    `json:"retries"`
+	RetryBackoff       string `json:"retryBackoff"`
+	Listen             string `json:"listen"`
 	Save               bool   `json:"save"`
 	Install            bool   `json:"install"`
 	Uninstall          bool   `json:"uninstall"`
 	LogLevel           string `json:"logLevel"`
+	LogFormat          string `json:"logFormat"`
+	LogFile            string `json:"logFile"`
+	LogMaxSize         int    `json:"logMaxSize"`
+	LogMaxAge          int    `json:"logMaxAge"`
+	LogMaxBackups      int    `json:"logMaxBackups"`
 	License            bool   `json:"license"`
+
+	// Templates is the templates section of the configuration file; it has no flag
+	Templates *TemplateSettings `json:"templates,omitempty"`
+
+	// secretRefs holds the references ResolveSecrets replaced, keyed by setting, so they
+	// are saved instead of the secrets
+	secretRefs map[string]string
+}
+
+// TemplateText overrides the description and remediation templates of one component type.
+type TemplateText struct {
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Remediation string `json:"remediation,omitempty" yaml:"remediation,omitempty"`
 }
 
-func validateArguments(args *Arguments) error {
+// TemplateSettings override the text of uploaded findings; types or fields left out keep
+// the default templates.
+type TemplateSettings struct {
+	Library     TemplateText `json:"library" yaml:"library"`
+	Application TemplateText `json:"application" yaml:"application"`
+	OS          TemplateText `json:"os" yaml:"os"`
+}
+
+// ValidateArguments reports the first setting missing to talk to Wiz.
+func ValidateArguments(args *Arguments) error {
 	if args.WizClientID == "" {
 		return errors.New("WizClientID is required")
 	}
@@ -51,80 +101,122 @@ func validateArguments(args *Arguments) error {
 	return nil
 }
 
-func saveConfig(config *Arguments, filePath string) error {
-	config.Install = false // Ensure Install is always false when saving config
+// FlagGroup selects the flags a command accepts.
+type FlagGroup int
 
-	// Get the directory path from the file path
-	dir := filepath.Dir(filePath)
+const (
+	// ConnectionFlags are the Wiz credentials, API URLs and the scanned asset
+	ConnectionFlags FlagGroup = iota
+	// OptionalConnectionFlags are the ConnectionFlags for commands that run without them
+	OptionalConnectionFlags
+	// ScanFlags choose the scan engine and the SBOM export
+	ScanFlags
+	// CompareFlags shape the comparison, its report and the local history
+	CompareFlags
+	// PublishFlags control how findings are uploaded
+	PublishFlags
+	// DaemonFlags schedule the runs of the daemon and its status endpoint
+	DaemonFlags
+)
 
-	// Check if the directory exists, if not, create it
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
+// registerFlags defines the flags of groups on fs, storing their values in args.
+func registerFlags(fs *flag.FlagSet, args *Arguments, groups ...FlagGroup) {
+	for _, group := range groups {
+		switch group {
+		case ConnectionFlags, OptionalConnectionFlags:
+			fs.StringVar(&args.WizClientID, "wizClientId", "", "Wiz Client ID")
+			fs.StringVar(&args.WizClientSecret, "wizClientSecret", "", "Wiz Client Secret")
+			fs.StringVar(&args.WizQueryURL, "wizQueryUrl", "", "Wiz Query URL")
+			fs.StringVar(&args.WizAuthURL, "wizAuthUrl", "", "Wiz Auth URL")
+			fs.StringVar(&args.ScanSubscriptionID, "scanSubscriptionId", "", "Scan Subscription ID")
+			fs.StringVar(&args.ScanCloudType, "scanCloudType", "", "Scan Cloud Type")
+			fs.StringVar(&args.ScanProviderID, "scanProviderId", "", "Scan Provider ID")
+			fs.StringVar(&args.Proxy, "proxy", "", "HTTP(S) proxy URL for the Wiz API and the wizcli download")
+		case ScanFlags:
+			fs.StringVar(&args.Scanner, "scanner", "wizcli", "Scan engine (wizcli, trivy, grype, sbom)")
+			fs.StringVar(&args.ScannerPath, "scannerPath", "", "Path to the trivy or grype executable")
+			fs.StringVar(&args.ScannerReport, "scannerReport", "", "Existing trivy or grype JSON report, or comma separated SBOM files, to use instead of scanning")
+			fs.StringVar(&args.ScanPaths, "scanPaths", "", "Comma separated directories to scan instead of every top-level directory or drive")
+			fs.StringVar(&args.Exclude, "exclude", "", "Comma separated glob patterns of directories and files to leave out of the scan results")
+			fs.StringVar(&args.SBOMOutput, "sbomOutput", "", "Write an SBOM of everything found on the host to this file")
+			fs.StringVar(&args.SBOMFormat, "sbomFormat", "cyclonedx", "SBOM output format (cyclonedx, spdx)")
+		case CompareFlags:
+			fs.StringVar(&args.Report, "report", "", "Print the comparison verdicts as a report (table, json, markdown)")
+			fs.StringVar(&args.ReportFile, "reportFile", "", "Write the comparison report to this file instead of stdout")
+			fs.StringVar(&args.Policy, "policy", "", "YAML policy file deciding which findings are published and when the run fails")
+			fs.StringVar(&args.Suppressions, "suppressions", "", "Comma separated suppression files or OpenVEX documents of accepted risks")
+			fs.BoolVar(&args.History, "history", true, "Record the findings of this run in the local history database")
+			fs.IntVar(&args.HistoryRuns, "historyRuns", 100, "Number of runs the history database keeps, 0 to keep all")
+		case PublishFlags:
+			fs.IntVar(&args.ChunkMaxFindings, "chunkMaxFindings", 10000, "Maximum number of findings per upload, 0 for no limit")
+			fs.IntVar(&args.ChunkMaxBytes, "chunkMaxBytes", 50*1024*1024, "Maximum size of an upload in bytes, 0 for no limit")
+			fs.StringVar(&args.FindingsOutput, "findingsOutput", "", "Comma separated reports of the findings to publish, as format=path or a path ending in .json, .sarif, .csv, .xml (junit) or .html")
+			fs.BoolVar(&args.DryRun, "dry-run", false, "Compare and write the findings reports, but don't publish anything to Wiz")
+		case DaemonFlags:
+			fs.StringVar(&args.Schedule, "schedule", "0 2 * * *", "Cron expression of when the daemon runs, in local time (or @hourly, @daily...)")
+			fs.StringVar(&args.Jitter, "jitter", "1h", "Random delay of up to this duration added to each scheduled run")
+			fs.IntVar(&args.Retries, "retries", 3, "Number of times a failed run is retried before waiting for the next one")
+			fs.StringVar(&args.RetryBackoff, "retryBackoff", "5m", "Wait before the first retry, doubling for each one after it")
+			fs.StringVar(&args.Listen, "listen", "127.0.0.1:8787", "Address of the /healthz and /status endpoints, empty to disable them")
 		}
 	}
+}
 
-	// Marshal the config struct to JSON
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	// Encode the JSON data using base64
-	encodedData := base64.StdEncoding.EncodeToString(data)
+// registerLogFlags adds the flags every command has to choose the log format and file.
+func registerLogFlags(fs *flag.FlagSet, args *Arguments) {
+	fs.StringVar(&args.LogFormat, "logFormat", "text", "Log format (text, json)")
+	fs.StringVar(&args.LogFile, "logFile", "", "Write the log to this file instead of stderr")
+	fs.IntVar(&args.LogMaxSize, "logMaxSize", 10, "Size in MB at which the log file is rotated, 0 to never rotate")
+	fs.IntVar(&args.LogMaxAge, "logMaxAge", 30, "Days rotated log files are kept, 0 to keep them regardless of age")
+	fs.IntVar(&args.LogMaxBackups, "logMaxBackups", 5, "Number of rotated log files kept, 0 to keep all")
+}
 
-	// Convert encoded data to byte slice for writing to file
-	byteData := []byte(encodedData)
+// registerAllFlags defines every setting on fs.
+func registerAllFlags(fs *flag.FlagSet, args *Arguments) {
+	fs.StringVar(&args.LogLevel, "logLevel", "info", "Set log level (info, error, etc.)")
+	registerLogFlags(fs, args)
+	registerFlags(fs, args, ConnectionFlags, ScanFlags, CompareFlags, PublishFlags, DaemonFlags)
+}
 
-	// Write the base64 encoded data to the file with 0600 permissions to ensure the file is only accessible to the user
-	if err := os.WriteFile(filePath, byteData, 0600); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
-	}
+// LoadArguments is LoadConfig without the sources of the settings.
+func LoadArguments(fs *flag.FlagSet, arguments []string, groups ...FlagGroup) (*Arguments, error) {
+	args, _, err := LoadConfig(fs, arguments, groups...)
+	return args, err
+}
 
-	return nil
+// DefaultArguments returns the arguments with every flag at its default value.
+func DefaultArguments() *Arguments {
+	args := &Arguments{}
+	registerAllFlags(flag.NewFlagSet("defaults", flag.ContinueOnError), args)
+	return args
 }
 
-func readConfig(filePath string, config *Arguments) error {
-	encodedData, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
-	}
-	// Decode the base64-encoded data
-	decodedData, err := base64.StdEncoding.DecodeString(string(encodedData))
-	if err != nil {
-		return fmt.Errorf("failed to decode base64 data: %w", err)
-	}
-	// Unmarshal the JSON data into the Arguments struct
-	if err = json.Unmarshal(decodedData, config); err != nil {
-		return fmt.Errorf("failed to unmarshal config: %w", err)
+// DefaultConfigPath is the configuration file read when -config isn't given:
+// the one written by -install if it exists, config.json in the working directory otherwise.
+func DefaultConfigPath() string {
+	if path, err := InstalledConfigPath(); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
 	}
-
-	return nil
+	return "config.json"
 }
 
+// ProcessArguments parses the flags of a run without a command, layering the environment
+// and the configuration file under them like LoadConfig.
 func ProcessArguments() (*Arguments, error) {
 	args := &Arguments{}
 	var configFilePath string
-	var logLevel string
-
-	flag.StringVar(&logLevel, "logLevel", "info", "Set log level (info, error, etc.)")
-	flag.StringVar(&args.WizClientID, "wizClientId", "", "Wiz Client ID")
-	flag.StringVar(&args.WizClientSecret, "wizClientSecret", "", "Wiz Client Secret")
-	flag.StringVar(&args.WizQueryURL, "wizQueryUrl", "", "Wiz Query URL")
-	flag.StringVar(&args.WizAuthURL, "wizAuthUrl", "", "Wiz Auth URL")
-	flag.StringVar(&args.ScanSubscriptionID, "scanSubscriptionId", "", "Scan Subscription ID")
-	flag.StringVar(&args.ScanCloudType, "scanCloudType", "", "Scan Cloud Type")
-	flag.StringVar(&args.ScanProviderID, "scanProviderId", "", "Scan Provider ID")
+
+	registerAllFlags(flag.CommandLine, args)
 	flag.BoolVar(&args.Save, "save", false, "Set to true to save the configuration (ignored if install flag is set)")
-	flag.StringVar(&configFilePath, "config", "config.json", "Path to the configuration file (ignored if install flag is set)")
+	flag.StringVar(&configFilePath, "config", DefaultConfigPath(), "Path to the configuration file, or WIZ_CONFIG (ignored if install flag is set)")
 	flag.BoolVar(&args.Install, "install", false, "Install the application")
 	flag.BoolVar(&args.Uninstall, "uninstall", false, "Uninstall the application")
 	flag.BoolVar(&args.License, "license", false, "Print License and Support Information")
 
 	flag.Parse()
 
-	args.LogLevel = logLevel
-
 	// Print Support info
 	if args.License {
 		fmt.Println(`
@@ -151,34 +243,17 @@ func ProcessArguments() (*Arguments, error) {
 		return args, nil
 	}
 
-	currInstall := args.Install
-	// If the save option isn't flagged
-	if !args.Save {
-
-		// Validate the arguments
-		if err := validateArguments(args); err != nil {
-			// If validation fails, attempt to load arguments from the config file
-			if err := readConfig(configFilePath, args); err != nil {
-				// If loading fails, return an error
-				return nil, fmt.Errorf("error reading config file: %v", err)
-			}
-			// If loading succeeds, validate arguments again
-			if err := validateArguments(args); err != nil {
-				// If re-validation fails, return an error
-				return nil, fmt.Errorf("error validating arguments: %v", err)
-			}
-		}
+	if _, err := applyConfigLayers(flag.CommandLine, args, configFilePath, args.Save); err != nil {
+		return nil, err
+	}
+	if err := ValidateArguments(args); err != nil {
+		return nil, fmt.Errorf("error validating arguments: %v", err)
 	}
 
 	if args.Save {
-		if err := validateArguments(args); err != nil {
-			return nil, fmt.Errorf("error validating arguments: %v", err)
-		}
 		if err := saveConfig(args, configFilePath); err != nil {
 			return nil, fmt.Errorf("error saving config: %v", err)
 		}
 	}
-
-	args.Install = currInstall
 	return args, nil
 }
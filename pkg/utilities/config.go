@@ -0,0 +1,520 @@
+package utilities
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jtb75/wiz-scan/pkg/secrets"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// SecretKeys are the settings masked whenever the configuration is printed.
+var SecretKeys = map[string]bool{
+	"wizClientSecret": true,
+}
+
+// ModeKeys are command line switches rather than settings. They are never read from the
+// environment or the configuration file, nor saved to it.
+var ModeKeys = map[string]bool{
+	"save":      true,
+	"install":   true,
+	"uninstall": true,
+	"license":   true,
+}
+
+// Sources maps each setting to where its value came from: "flag", "env WIZ_...",
+// "file <path>" or "default".
+type Sources map[string]string
+
+// ConfigFileError is returned when the configuration file can't be read or decrypted.
+type ConfigFileError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigFileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigFileError) Unwrap() error {
+	return e.Err
+}
+
+// configFormat is how a configuration file is encoded.
+type configFormat int
+
+const (
+	// formatLegacy is base64 encoded JSON, as -save and -install wrote before encryption
+	formatLegacy configFormat = iota
+	formatEncrypted
+	formatJSON
+	formatYAML
+)
+
+// EnvName is the environment variable of a setting: WIZ_ followed by the setting in
+// upper snake case, such as WIZ_CLIENT_ID for wizClientId or WIZ_DRY_RUN for dry-run.
+func EnvName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		switch {
+		case r == '-':
+			b.WriteRune('_')
+		case unicode.IsUpper(r) && i > 0:
+			b.WriteRune('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	name := b.String()
+	if !strings.HasPrefix(name, "WIZ_") {
+		name = "WIZ_" + name
+	}
+	return name
+}
+
+// LoadConfig parses the flags of a command and fills in every setting not given as a flag
+// from the environment, then the configuration file, then the flag default. Commands that
+// talk to Wiz pass ConnectionFlags and get validated credentials. When only the
+// configuration file can't be read, the arguments from the other layers are returned
+// together with a *ConfigFileError.
+func LoadConfig(fs *flag.FlagSet, arguments []string, groups ...FlagGroup) (*Arguments, Sources, error) {
+	args := &Arguments{}
+	var configFilePath string
+	fs.StringVar(&args.LogLevel, "logLevel", "info", "Set log level (info, error, etc.)")
+	registerLogFlags(fs, args)
+	fs.StringVar(&configFilePath, "config", DefaultConfigPath(), "Path to the configuration file (or WIZ_CONFIG)")
+	registerFlags(fs, args, groups...)
+
+	if err := fs.Parse(arguments); err != nil {
+		return nil, nil, err
+	}
+	sources, err := applyConfigLayers(fs, args, configFilePath, false)
+	var fileErr *ConfigFileError
+	if errors.As(err, &fileErr) {
+		return args, sources, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, group := range groups {
+		if group == ConnectionFlags {
+			if err := ValidateArguments(args); err != nil {
+				return nil, nil, fmt.Errorf("error validating arguments: %v", err)
+			}
+		}
+	}
+	return args, sources, nil
+}
+
+// applyConfigLayers sets the flags of fs that weren't given on the command line from the
+// environment or the configuration file, and records where every setting came from. The
+// templates section, which has no flag, is read into args.
+// A missing configuration file is only an error when -config or WIZ_CONFIG names it and
+// it isn't about to be created.
+func applyConfigLayers(fs *flag.FlagSet, args *Arguments, configFilePath string, creating bool) (Sources, error) {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	if path, ok := os.LookupEnv(EnvName("config")); ok && !explicit["config"] {
+		configFilePath = path
+		explicit["config"] = true
+	}
+
+	values, _, fileErr := readConfigValues(configFilePath)
+	if errors.Is(fileErr, os.ErrNotExist) && (!explicit["config"] || creating) {
+		fileErr = nil
+	}
+	known := configKeys()
+	for key := range values {
+		if !known[key] && !ModeKeys[key] {
+			logrus.Warnf("Ignoring unknown setting %q in %s", key, configFilePath)
+		}
+	}
+
+	sources := make(Sources)
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || f.Name == "config" || ModeKeys[f.Name] {
+			return
+		}
+		if explicit[f.Name] {
+			sources[f.Name] = "flag"
+			return
+		}
+		if value, ok := os.LookupEnv(EnvName(f.Name)); ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value for %s in %s: %w", f.Name, EnvName(f.Name), setErr)
+			}
+			sources[f.Name] = "env " + EnvName(f.Name)
+			return
+		}
+		// Per-run flags such as dry-run have no setting in the file and were warned about above
+		if value, ok := values[f.Name]; ok && value != nil && known[f.Name] {
+			if setErr := fs.Set(f.Name, settingString(value)); setErr != nil {
+				err = fmt.Errorf("invalid value for %s in %s: %w", f.Name, configFilePath, setErr)
+			}
+			sources[f.Name] = "file " + configFilePath
+			return
+		}
+		sources[f.Name] = "default"
+	})
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := values[templatesKey]; ok && value != nil {
+		if args.Templates, err = decodeTemplates(value, configFilePath); err != nil {
+			return nil, err
+		}
+		sources[templatesKey] = "file " + configFilePath
+	}
+	if fileErr != nil {
+		return sources, &ConfigFileError{Path: configFilePath, Err: fileErr}
+	}
+	return sources, nil
+}
+
+// templatesKey is the section of the configuration file holding TemplateSettings.
+const templatesKey = "templates"
+
+// decodeTemplates reads the templates section of a configuration file.
+func decodeTemplates(value interface{}, filePath string) (*TemplateSettings, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid templates in %s: %w", filePath, err)
+	}
+	templates := &TemplateSettings{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(templates); err != nil {
+		return nil, fmt.Errorf("invalid templates in %s: %w", filePath, err)
+	}
+	return templates, nil
+}
+
+// settingString turns a value decoded from a configuration file into flag syntax. Lists,
+// such as several suppression files in YAML, become comma separated.
+func settingString(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// configKeys returns the names of the settings a configuration file can hold.
+func configKeys() map[string]bool {
+	values, _ := ConfigValues(&Arguments{})
+	keys := make(map[string]bool, len(values)+1)
+	for key := range values {
+		keys[key] = true
+	}
+	keys[templatesKey] = true
+	return keys
+}
+
+// ConfigValues returns the settings of config keyed by their name in the configuration
+// file. Whole numbers are json.Number.
+func ConfigValues(config *Arguments) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	values := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	for key := range ModeKeys {
+		delete(values, key)
+	}
+	for key, ref := range config.secretRefs {
+		values[key] = ref
+	}
+	return values, nil
+}
+
+// ResolveSecrets replaces the Wiz credentials that are references, such as
+// file:///run/secrets/wiz or vault:secret/data/wiz#clientSecret, with the secrets they
+// point to. The references, not the secrets, are what is saved afterwards.
+func ResolveSecrets(args *Arguments) error {
+	for key, value := range secretSettings(args) {
+		if !secrets.IsReference(*value) {
+			continue
+		}
+		secret, err := secrets.Resolve(*value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", key, err)
+		}
+		if args.secretRefs == nil {
+			args.secretRefs = make(map[string]string)
+		}
+		args.secretRefs[key] = *value
+		*value = secret
+	}
+	return nil
+}
+
+// RefreshSecrets resolves the references ResolveSecrets replaced again, for a process that
+// keeps running while the secrets they point to are rotated.
+func RefreshSecrets(args *Arguments) error {
+	settings := secretSettings(args)
+	for key, ref := range args.secretRefs {
+		secret, err := secrets.Resolve(ref)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", key, err)
+		}
+		*settings[key] = secret
+	}
+	return nil
+}
+
+// secretSettings are the settings that can be secret references.
+func secretSettings(args *Arguments) map[string]*string {
+	return map[string]*string{
+		"wizClientId":     &args.WizClientID,
+		"wizClientSecret": &args.WizClientSecret,
+	}
+}
+
+// readConfigValues decodes a configuration file in any of the supported formats. Legacy
+// base64 files are encrypted in place, as they hold the client secret in the clear.
+func readConfigValues(filePath string) (map[string]interface{}, configFormat, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read config file: %w", err)
+	}
+	values, format, err := decodeConfig(filePath, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if format == formatJSON || format == formatYAML {
+		if err := openSecrets(values, filePath); err != nil {
+			return nil, 0, err
+		}
+	}
+	if format != formatLegacy {
+		return values, format, nil
+	}
+
+	for key := range ModeKeys {
+		delete(values, key)
+	}
+	if err := writeConfigValues(values, filePath, formatEncrypted); err != nil {
+		logrus.Warnf("Unable to encrypt the configuration file %s: %v", filePath, err)
+		return values, format, nil
+	}
+	logrus.Infof("Encrypted the configuration file %s", filePath)
+	return values, formatEncrypted, nil
+}
+
+func decodeConfig(filePath string, data []byte) (map[string]interface{}, configFormat, error) {
+	values := make(map[string]interface{})
+	trimmed := bytes.TrimSpace(data)
+
+	if ext := strings.ToLower(filepath.Ext(filePath)); ext != ".yaml" && ext != ".yml" {
+		if bytes.HasPrefix(trimmed, []byte("{")) {
+			if err := decodeJSONConfig(trimmed, &values); err != nil {
+				return nil, 0, fmt.Errorf("failed to parse config file %s: %w", filePath, err)
+			}
+			if !isEncryptedConfig(values) {
+				return values, formatJSON, nil
+			}
+			plaintext, err := decryptConfig(filePath, trimmed)
+			if err != nil {
+				return nil, 0, err
+			}
+			values = make(map[string]interface{})
+			if err := decodeJSONConfig(plaintext, &values); err != nil {
+				return nil, 0, fmt.Errorf("failed to parse config file %s: %w", filePath, err)
+			}
+			return values, formatEncrypted, nil
+		}
+		if decoded, err := base64.StdEncoding.DecodeString(string(trimmed)); err == nil && bytes.HasPrefix(bytes.TrimSpace(decoded), []byte("{")) {
+			if err := decodeJSONConfig(decoded, &values); err != nil {
+				return nil, 0, fmt.Errorf("failed to parse config file %s: %w", filePath, err)
+			}
+			return values, formatLegacy, nil
+		}
+	}
+
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse config file %s: %w", filePath, err)
+	}
+	return values, formatYAML, nil
+}
+
+func decodeJSONConfig(data []byte, values *map[string]interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(values)
+}
+
+// ReadConfigFile returns the settings saved in filePath, with defaults for the rest.
+func ReadConfigFile(filePath string) (*Arguments, error) {
+	config := &Arguments{}
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	registerAllFlags(fs, config)
+
+	values, _, err := readConfigValues(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := values[templatesKey]; ok && value != nil {
+		if config.Templates, err = decodeTemplates(value, filePath); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range values {
+		if fs.Lookup(key) == nil || ModeKeys[key] || value == nil {
+			continue
+		}
+		if err := fs.Set(key, settingString(value)); err != nil {
+			return nil, fmt.Errorf("invalid value for %s in %s: %w", key, filePath, err)
+		}
+	}
+	return config, nil
+}
+
+// SaveConfigFile writes config to filePath. An existing YAML or JSON file stays plain
+// text; a new file is YAML when its name ends in .yaml or .yml and encrypted otherwise.
+func SaveConfigFile(config *Arguments, filePath string) error {
+	return saveConfig(config, filePath)
+}
+
+func saveConfig(config *Arguments, filePath string) error {
+	format := formatEncrypted
+	if _, existing, err := readConfigValues(filePath); err == nil && existing != formatLegacy {
+		format = existing
+	} else if ext := strings.ToLower(filepath.Ext(filePath)); ext == ".yaml" || ext == ".yml" {
+		format = formatYAML
+	}
+
+	values, err := ConfigValues(config)
+	if err != nil {
+		return err
+	}
+	return writeConfigValues(values, filePath, format)
+}
+
+func writeConfigValues(values map[string]interface{}, filePath string, format configFormat) error {
+	var data []byte
+	var err error
+	switch format {
+	case formatYAML:
+		if values, err = sealSecrets(values); err == nil {
+			data, err = yaml.Marshal(plainNumbers(values))
+		}
+	case formatJSON:
+		if values, err = sealSecrets(values); err == nil {
+			data, err = json.MarshalIndent(values, "", "  ")
+		}
+	default:
+		if data, err = json.Marshal(values); err == nil {
+			data, err = encryptConfig(data)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	// The file holds the client secret, so only its owner may read it
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// sealedPrefix starts a secret setting encrypted inside a plain text configuration file.
+const sealedPrefix = "encrypted:"
+
+// sealSecrets returns values with the secret settings encrypted like an encrypted
+// configuration file, so a YAML or JSON file never holds them in the clear. Empty settings
+// and secret references are kept as they are.
+func sealSecrets(values map[string]interface{}) (map[string]interface{}, error) {
+	sealed := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		sealed[key] = value
+		secret, ok := value.(string)
+		if !SecretKeys[key] || !ok || secret == "" || secrets.IsReference(secret) || strings.HasPrefix(secret, sealedPrefix) {
+			continue
+		}
+		envelope, err := encryptConfig([]byte(secret))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s: %w", key, err)
+		}
+		sealed[key] = sealedPrefix + base64.StdEncoding.EncodeToString(envelope)
+	}
+	return sealed, nil
+}
+
+// openSecrets decrypts the secret settings sealSecrets encrypted, and warns about those
+// written in the clear by hand.
+func openSecrets(values map[string]interface{}, filePath string) error {
+	for key := range SecretKeys {
+		secret, ok := values[key].(string)
+		if !ok || secret == "" || secrets.IsReference(secret) {
+			continue
+		}
+		encoded, sealed := strings.CutPrefix(secret, sealedPrefix)
+		if !sealed {
+			logrus.Warnf("%s is in plain text in %s; save it with `wiz-scan config set %s=...` to encrypt it, or use a secret reference", key, filePath, key)
+			continue
+		}
+		envelope, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("invalid encrypted %s in %s: %w", key, filePath, err)
+		}
+		plaintext, err := decryptConfig(filePath, envelope)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", key, err)
+		}
+		values[key] = string(plaintext)
+	}
+	return nil
+}
+
+// plainNumbers converts json.Number values so YAML writes them as numbers, not strings.
+func plainNumbers(values map[string]interface{}) map[string]interface{} {
+	plain := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				value = i
+			} else if f, err := n.Float64(); err == nil {
+				value = f
+			}
+		}
+		plain[key] = value
+	}
+	return plain
+}
+
+// SortedKeys returns the keys of values in order.
+func SortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package utilities
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigFileTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "scanner: trivy\ntemplates:\n  os:\n    remediation: \"Patch {{.Package}}.\"\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ReadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Templates == nil || config.Templates.OS.Remediation != "Patch {{.Package}}." {
+		t.Fatalf("templates are %+v", config.Templates)
+	}
+
+	// Saving keeps the section
+	config.Scanner = "grype"
+	if err := SaveConfigFile(config, path); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ReadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Scanner != "grype" || saved.Templates == nil || saved.Templates.OS.Remediation != "Patch {{.Package}}." {
+		t.Errorf("saved configuration is %+v with templates %+v", saved, saved.Templates)
+	}
+
+	if err := os.WriteFile(path, []byte("templates:\n  os:\n    remedation: x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadConfigFile(path); err == nil {
+		t.Error("a misspelled template field was accepted")
+	}
+}
+
+func TestPlainConfigEncryptsSecrets(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse battery staple")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	config := &Arguments{WizClientID: "client", WizClientSecret: "s3cr3t-value", Scanner: "trivy"}
+	if err := SaveConfigFile(config, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t-value") {
+		t.Fatalf("the secret is saved in the clear:\n%s", data)
+	}
+	if !strings.Contains(string(data), "scanner: trivy") {
+		t.Errorf("the other settings aren't plain text:\n%s", data)
+	}
+
+	saved, err := ReadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.WizClientSecret != "s3cr3t-value" || saved.WizClientID != "client" {
+		t.Errorf("read back %q and %q", saved.WizClientID, saved.WizClientSecret)
+	}
+}
+
+func TestSaveConfigKeepsSecretReferences(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse battery staple")
+	t.Setenv("WIZ_SCAN_TEST_SECRET", "s3cr3t-value")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	config := &Arguments{WizClientID: "client", WizClientSecret: "env:WIZ_SCAN_TEST_SECRET"}
+	if err := ResolveSecrets(config); err != nil {
+		t.Fatal(err)
+	}
+	if config.WizClientSecret != "s3cr3t-value" {
+		t.Fatalf("resolved %q", config.WizClientSecret)
+	}
+	if err := SaveConfigFile(config, path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "wizClientSecret: env:WIZ_SCAN_TEST_SECRET") || strings.Contains(string(data), "s3cr3t-value") {
+		t.Errorf("saved configuration:\n%s", data)
+	}
+}
+
+func TestConfigFileIgnoresRunFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"scanner": "trivy", "dry-run": true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvName("config"), path)
+
+	fs := flag.NewFlagSet("publish", flag.ContinueOnError)
+	args, sources, err := LoadConfig(fs, nil, ScanFlags, PublishFlags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.Scanner != "trivy" {
+		t.Errorf("scanner is %q, want trivy from the file", args.Scanner)
+	}
+	if args.DryRun || sources["dry-run"] != "default" {
+		t.Errorf("dry-run is %v from %q, the file can't set it", args.DryRun, sources["dry-run"])
+	}
+}
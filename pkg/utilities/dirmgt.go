@@ -2,6 +2,7 @@ package utilities
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 )
@@ -87,3 +88,13 @@ func CreateTempFile() (*os.File, error) {
 	}
 	return file, nil
 }
+
+// IsPrivileged reports whether wiz-scan runs as root, or as an administrator on Windows,
+// which scanning every directory and creating VSS snapshots need.
+func IsPrivileged() bool {
+	if runtime.GOOS == "windows" {
+		// Only administrators may list the sessions of the server service
+		return exec.Command("net", "session").Run() == nil
+	}
+	return os.Geteuid() == 0
+}
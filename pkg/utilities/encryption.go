@@ -0,0 +1,265 @@
+package utilities
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv holds the passphrase of configuration files that aren't bound to one host.
+const PassphraseEnv = "WIZ_CONFIG_PASSPHRASE"
+
+const (
+	configCipher  = "aes-256-gcm"
+	keyMachine    = "machine"
+	keyPassphrase = "passphrase"
+)
+
+// encryptedConfig is how an encrypted configuration file is stored. The settings are
+// encrypted with a key derived from the machine ID and a key file only the owner can read,
+// or from a passphrase.
+type encryptedConfig struct {
+	Encryption string `json:"encryption"`
+	Key        string `json:"key"`
+	KeyFile    string `json:"keyFile,omitempty"`
+	// Host identifies the machine the file was encrypted on without revealing its ID
+	Host       string `json:"host,omitempty"`
+	HostName   string `json:"hostName,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// isEncryptedConfig reports whether decoded JSON values are an encryptedConfig.
+func isEncryptedConfig(values map[string]interface{}) bool {
+	_, cipherText := values["ciphertext"]
+	_, encryption := values["encryption"]
+	return cipherText && encryption
+}
+
+// encryptConfig encrypts plaintext with the passphrase in WIZ_CONFIG_PASSPHRASE when it is
+// set, and with the machine key otherwise.
+func encryptConfig(plaintext []byte) ([]byte, error) {
+	envelope := encryptedConfig{Encryption: configCipher}
+	var key []byte
+	var err error
+
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		envelope.Key = keyPassphrase
+		envelope.Salt = make([]byte, 16)
+		if _, err := rand.Read(envelope.Salt); err != nil {
+			return nil, err
+		}
+		if key, err = passphraseKey(passphrase, envelope.Salt); err != nil {
+			return nil, err
+		}
+	} else {
+		envelope.Key = keyMachine
+		if envelope.KeyFile, err = machineKeyFile(); err != nil {
+			return nil, err
+		}
+		var id string
+		if id, err = machineID(); err != nil {
+			return nil, err
+		}
+		envelope.Host = hostFingerprint(id)
+		if key, err = machineKey(id, envelope.KeyFile, true); err != nil {
+			return nil, err
+		}
+		envelope.HostName, _ = os.Hostname()
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(envelope.Nonce); err != nil {
+		return nil, err
+	}
+	envelope.Ciphertext = gcm.Seal(nil, envelope.Nonce, plaintext, []byte(envelope.Host))
+	return json.MarshalIndent(envelope, "", "  ")
+}
+
+// decryptConfig returns the settings of the encrypted configuration file filePath.
+func decryptConfig(filePath string, data []byte) ([]byte, error) {
+	var envelope encryptedConfig
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted config file %s: %w", filePath, err)
+	}
+	if envelope.Encryption != configCipher {
+		return nil, fmt.Errorf("config file %s uses unsupported encryption %q", filePath, envelope.Encryption)
+	}
+
+	var key []byte
+	var err error
+	switch envelope.Key {
+	case keyPassphrase:
+		passphrase := os.Getenv(PassphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("config file %s is encrypted with a passphrase, set %s to read it", filePath, PassphraseEnv)
+		}
+		if key, err = passphraseKey(passphrase, envelope.Salt); err != nil {
+			return nil, err
+		}
+	case keyMachine:
+		var id string
+		if id, err = machineID(); err != nil {
+			return nil, fmt.Errorf("unable to decrypt config file %s: %w", filePath, err)
+		}
+		// Checked before the key file, which another host doesn't have
+		if hostFingerprint(id) != envelope.Host {
+			return nil, fmt.Errorf("config file %s was encrypted on another host (%s) and can only be read there; "+
+				"save the configuration again on this host, or use %s for a file that moves between hosts",
+				filePath, envelope.HostName, PassphraseEnv)
+		}
+		if key, err = machineKey(id, envelope.KeyFile, false); err != nil {
+			return nil, fmt.Errorf("unable to decrypt config file %s: %w", filePath, err)
+		}
+	default:
+		return nil, fmt.Errorf("config file %s uses unsupported key %q", filePath, envelope.Key)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, []byte(envelope.Host))
+	if err != nil {
+		if envelope.Key == keyPassphrase {
+			return nil, fmt.Errorf("unable to decrypt config file %s: wrong passphrase or the file was modified", filePath)
+		}
+		return nil, fmt.Errorf("unable to decrypt config file %s: the key file %s changed or the file was modified", filePath, envelope.KeyFile)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func passphraseKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+// hostFingerprint identifies the host with machine ID id without revealing the ID.
+func hostFingerprint(id string) string {
+	fingerprint := sha256.Sum256([]byte("wiz-scan host " + id))
+	return hex.EncodeToString(fingerprint[:8])
+}
+
+// machineKey derives the key of the host with machine ID id from the secret in keyFile,
+// creating the key file when create is set.
+func machineKey(id, keyFile string, create bool) ([]byte, error) {
+	secret, err := os.ReadFile(keyFile)
+	if errors.Is(err, os.ErrNotExist) && create {
+		secret, err = createKeyFile(keyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id))
+	return mac.Sum(nil), nil
+}
+
+func createKeyFile(keyFile string) ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// Restrict the file before the secret is in it
+	if err := restrictKeyFile(keyFile); err != nil {
+		file.Close()
+		os.Remove(keyFile)
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Write(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// machineKeyFile is next to the installed configuration for administrators, so only they
+// can decrypt it, and in the user's configuration directory for everyone else.
+func machineKeyFile() (string, error) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		config, err := InstalledConfigPath()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(filepath.Dir(config), "config.key"), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find a directory for the key file: %w", err)
+	}
+	return filepath.Join(dir, "wiz-scan", "config.key"), nil
+}
+
+// machineID returns the ID the operating system assigned to this installation.
+func machineID() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		out, err := exec.Command("reg", "query", `HKLM\SOFTWARE\Microsoft\Cryptography`, "/v", "MachineGuid").Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to read MachineGuid: %w", err)
+		}
+		return lastFieldOf(out, "MachineGuid")
+	case "darwin":
+		out, err := exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to read IOPlatformUUID: %w", err)
+		}
+		id, err := lastFieldOf(out, "IOPlatformUUID")
+		return strings.Trim(id, `"`), err
+	default:
+		for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+			if data, err := os.ReadFile(path); err == nil {
+				if id := strings.TrimSpace(string(data)); id != "" {
+					return id, nil
+				}
+			}
+		}
+		return "", errors.New("no machine ID in /etc/machine-id or /var/lib/dbus/machine-id")
+	}
+}
+
+// lastFieldOf returns the last field of the first line of out that contains name.
+func lastFieldOf(out []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); strings.Contains(scanner.Text(), name) && len(fields) > 0 {
+			return fields[len(fields)-1], nil
+		}
+	}
+	return "", fmt.Errorf("%s not found", name)
+}
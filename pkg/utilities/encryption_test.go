@@ -0,0 +1,45 @@
+package utilities
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecryptConfigFromAnotherHost(t *testing.T) {
+	id, err := machineID()
+	if err != nil {
+		t.Skipf("no machine ID: %v", err)
+	}
+
+	// The key file only exists on the host that encrypted the file
+	envelope := encryptedConfig{
+		Encryption: configCipher,
+		Key:        keyMachine,
+		KeyFile:    filepath.Join(t.TempDir(), "config.key"),
+		Host:       hostFingerprint("another " + id),
+		HostName:   "web-02",
+		Nonce:      make([]byte, 12),
+		Ciphertext: []byte("not decrypted"),
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = decryptConfig("config.json", data)
+	if err == nil || !strings.Contains(err.Error(), "encrypted on another host (web-02)") {
+		t.Errorf("decrypting a file from another host failed with %v", err)
+	}
+
+	// On this host the missing key file is the problem
+	envelope.Host = hostFingerprint(id)
+	if data, err = json.Marshal(envelope); err != nil {
+		t.Fatal(err)
+	}
+	_, err = decryptConfig("config.json", data)
+	if err == nil || !strings.Contains(err.Error(), "failed to read key file") {
+		t.Errorf("decrypting without the key file failed with %v", err)
+	}
+}
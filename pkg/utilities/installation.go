@@ -49,6 +49,30 @@ func CopyFile(src, dst string) error {
 	return nil
 }
 
+// InstalledConfigPath returns where -install saves the configuration used by the scheduled task.
+func InstalledConfigPath() (string, error) {
+	if runtime.GOOS == "windows" {
+		programFilesDir := os.Getenv("ProgramFiles")
+		if programFilesDir == "" {
+			return "", fmt.Errorf("failed to determine Program Files directory")
+		}
+		return filepath.Join(programFilesDir, "Wiz-Scan", "config.txt"), nil
+	}
+	return "/etc/wiz-scan/config.txt", nil
+}
+
+// InstalledLogPath returns the log file of the scheduled task.
+func InstalledLogPath() (string, error) {
+	if runtime.GOOS == "windows" {
+		dir, err := StateDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "wiz-scan.log"), nil
+	}
+	return "/var/log/wiz-scan.log", nil
+}
+
 // InstallAndScheduleTask installs the application and sets up a scheduled task to run it daily.
 func InstallAndScheduleTask(args *Arguments) error {
 	var programFilesDir, configFilePath, wizScanDir string
@@ -81,11 +105,17 @@ func InstallAndScheduleTask(args *Arguments) error {
 		return err
 	}
 
+	// The scheduled task logs to a rotated file rather than relying on output redirection
+	if args.LogFile == "" {
+		if args.LogFile, err = InstalledLogPath(); err != nil {
+			return err
+		}
+	}
+
 	// Save configuration to file
-	if runtime.GOOS == "windows" {
-		configFilePath = filepath.Join(wizScanDir, "config.txt")
-	} else {
-		configFilePath = "/etc/wiz-scan/config.txt"
+	configFilePath, err = InstalledConfigPath()
+	if err != nil {
+		return err
 	}
 	err = saveConfig(args, configFilePath)
 	if err != nil {
@@ -135,8 +165,8 @@ func installCronJob(command string) error {
 	minute := startTime.Minute()
 	hour := startTime.Hour()
 
-	// Setting up the cron job command with log redirection and configuration option
-	cronJob := fmt.Sprintf("%d %d * * * %s >> /var/log/wiz-scan.log 2>&1\n", minute, hour, command)
+	// wiz-scan writes its own log, so only output outside the log, such as a crash, is redirected
+	cronJob := fmt.Sprintf("%d %d * * * %s >> /var/log/wiz-scan.out 2>&1\n", minute, hour, command)
 
 	// Adding the cron job to the user's crontab
 	cmd := exec.Command("bash", "-c", fmt.Sprintf("(crontab -l 2>/dev/null; echo '%s') | crontab -", cronJob))
@@ -223,6 +253,11 @@ func UninstallAndRemoveTask() error {
 		if err != nil {
 			return err
 		}
+		// The key the configuration was encrypted with is useless without it
+		keyFilePath := filepath.Join(filepath.Dir(configFilePath), "config.key")
+		if err := os.Remove(keyFilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
 	}
 
 	// Remove the scheduled task or cron job
@@ -263,6 +298,14 @@ func createScheduledTask(taskName, destinationPath string) error {
 	return nil
 }
 
+// ScheduledTaskInstalled reports whether -install scheduled the daily run on this host.
+func ScheduledTaskInstalled() (bool, error) {
+	if runtime.GOOS == "windows" {
+		return taskExists("WizScanTask")
+	}
+	return commandExists("wiz-scan"), nil
+}
+
 func taskExists(taskName string) (bool, error) {
 	cmd := exec.Command("schtasks", "/Query", "/TN", taskName)
 	output, err := cmd.CombinedOutput()
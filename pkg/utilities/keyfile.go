@@ -0,0 +1,9 @@
+//go:build !windows
+
+package utilities
+
+// restrictKeyFile limits access to the key file to its owner, which its 0600 mode
+// already does here.
+func restrictKeyFile(path string) error {
+	return nil
+}
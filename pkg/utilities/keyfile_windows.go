@@ -0,0 +1,23 @@
+package utilities
+
+import (
+	"fmt"
+	"os/exec"
+	"os/user"
+)
+
+// restrictKeyFile limits access to the key file to the current user, SYSTEM and
+// Administrators. Windows ignores the 0600 mode, so the file would otherwise inherit the
+// ACL of its directory, which lets every user read %ProgramData%.
+func restrictKeyFile(path string) error {
+	current, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("failed to find the current user: %w", err)
+	}
+	out, err := exec.Command("icacls", path, "/inheritance:r",
+		"/grant:r", current.Username+":F", "/grant:r", "*S-1-5-18:F", "/grant:r", "*S-1-5-32-544:F").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to restrict access to %s: %w: %s", path, err, out)
+	}
+	return nil
+}
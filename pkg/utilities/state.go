@@ -0,0 +1,74 @@
+package utilities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// StateDir returns the directory wiz-scan keeps state in between runs, creating it if needed.
+// It sits next to the installed config, or in the user cache directory when that isn't writable.
+func StateDir() (string, error) {
+	var dir string
+	if runtime.GOOS == "windows" {
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			programData = `C:\ProgramData`
+		}
+		dir = filepath.Join(programData, "Wiz-Scan")
+	} else {
+		dir = "/var/lib/wiz-scan"
+	}
+	if err := os.MkdirAll(dir, 0700); err == nil {
+		return dir, nil
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find a state directory: %w", err)
+	}
+	dir = filepath.Join(cacheDir, "wiz-scan")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return dir, nil
+}
+
+// ReadState decodes the JSON state file name from the state directory into v.
+// A missing file leaves v untouched.
+func ReadState(name string, v interface{}) error {
+	dir, err := StateDir()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse state file %s: %w", name, err)
+	}
+	return nil
+}
+
+// WriteState stores v as the JSON state file name in the state directory.
+func WriteState(name string, v interface{}) error {
+	dir, err := StateDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package vulnerability
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ChunkDataSourceID returns the data source ID of chunk index. The first chunk keeps the
+// configured ID so hosts that fit in one upload behave as before.
+func ChunkDataSourceID(baseID string, index int) string {
+	if index == 0 {
+		return baseID
+	}
+	return fmt.Sprintf("%s-%d", baseID, index+1)
+}
+
+// Chunk splits a single-source payload into payloads of at most maxFindings findings and
+// maxBytes bytes of compact JSON; a limit of zero or less is not enforced. Wiz replaces the
+// findings of an asset per data source, so every chunk gets its own data source ID from
+// ChunkDataSourceID and a later upload of one chunk doesn't wipe the findings of another.
+func Chunk(data IntegrationData, maxFindings, maxBytes int) ([]IntegrationData, error) {
+	if len(data.DataSources) != 1 {
+		return nil, fmt.Errorf("chunking needs exactly one data source, got %d", len(data.DataSources))
+	}
+	source := data.DataSources[0]
+
+	var chunks []IntegrationData
+	for _, asset := range source.Assets {
+		// Size the envelope with a long chunk ID so later chunks stay within maxBytes too
+		envelope := EmptyChunk(data, 9999, asset.AssetIdentifier)
+		overhead, err := jsonSize(envelope)
+		if err != nil {
+			return nil, err
+		}
+
+		current := make([]VulnerabilityFinding, 0)
+		size := overhead
+		flush := func() {
+			chunk := EmptyChunk(data, len(chunks), asset.AssetIdentifier)
+			chunk.DataSources[0].Assets[0].VulnerabilityFindings = current
+			chunks = append(chunks, chunk)
+			current = make([]VulnerabilityFinding, 0)
+			size = overhead
+		}
+
+		for _, finding := range asset.VulnerabilityFindings {
+			findingSize, err := jsonSize(finding)
+			if err != nil {
+				return nil, err
+			}
+			findingSize++ // separating comma
+			if maxBytes > 0 && overhead+findingSize > maxBytes {
+				return nil, fmt.Errorf("finding %s alone is %d bytes, above the %d byte chunk limit", finding.Id, overhead+findingSize, maxBytes)
+			}
+			if len(current) > 0 && ((maxFindings > 0 && len(current) >= maxFindings) || (maxBytes > 0 && size+findingSize > maxBytes)) {
+				flush()
+			}
+			current = append(current, finding)
+			size += findingSize
+		}
+		if len(current) > 0 {
+			flush()
+		}
+	}
+	return chunks, nil
+}
+
+// EmptyChunk returns chunk index of data for asset without findings. Publishing it clears
+// the findings an earlier, larger run uploaded under that chunk's data source.
+func EmptyChunk(data IntegrationData, index int, asset AssetIdentifier) IntegrationData {
+	source := data.DataSources[0]
+	return IntegrationData{
+		IntegrationId: data.IntegrationId,
+		DataSources: []DataSource{{
+			Id:           ChunkDataSourceID(source.Id, index),
+			AnalysisDate: source.AnalysisDate,
+			Assets: []Asset{{
+				AssetIdentifier:       asset,
+				VulnerabilityFindings: make([]VulnerabilityFinding, 0),
+			}},
+		}},
+	}
+}
+
+func jsonSize(v interface{}) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return len(data), nil
+}
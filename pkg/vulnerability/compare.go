@@ -3,11 +3,11 @@ package vulnerability
 import (
 	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/jtb75/wiz-scan/pkg/ecosystem"
+	"github.com/jtb75/wiz-scan/pkg/suppression"
 	"github.com/jtb75/wiz-scan/pkg/wizapi"
 	"github.com/jtb75/wiz-scan/pkg/wizcli"
 )
@@ -46,155 +46,294 @@ type VulnerabilityFinding struct {
 	Remediation             string `json:"remediation"`
 	ValidatedAtRuntime      bool   `json:"validatedAtRuntime"`
 	Description             string `json:"description"`
+	RiskAttributes
+
+	// Path is where the component was found. It isn't part of the Wiz schema and only
+	// used by local reports.
+	Path string `json:"-"`
+}
+
+// comparer holds the state of a single CompareVulnerabilities call.
+type comparer struct {
+	index        *knownIndex
+	externalId   string
+	suppressions *suppression.List
+	templates    *Templates
+	asset        Asset
+	verdicts     []Verdict
+	// legacyIDs are the legacy finding IDs already reused, see classify
+	legacyIDs map[string]bool
 }
 
-func CompareVulnerabilities(scanResult wizcli.AggregatedScanResults, knownVulns []wizapi.VulnerabilityNode, externalId string) (Asset, error) {
+// Options tune CompareVulnerabilities; the zero value uses no suppressions and the default templates.
+type Options struct {
+	// Suppressions cover vulnerabilities that are reported as suppressed instead of uploaded
+	Suppressions *suppression.List
+	// Templates render the description and remediation of findings
+	Templates *Templates
+}
 
-	// Instantiate assetVulns with an empty slice of VulnerabilityFinding
-	assetVulns := Asset{
-		VulnerabilityFindings: make([]VulnerabilityFinding, 0),
+// CompareVulnerabilities compares the scan results against the findings Wiz already knows about
+// for the asset and returns the findings that should be uploaded, along with a verdict explaining
+// the decision for every scanned vulnerability. The known findings are indexed once so matching
+// stays linear in the number of scanned and known vulnerabilities.
+func CompareVulnerabilities(scanResult wizcli.AggregatedScanResults, knownVulns []wizapi.VulnerabilityNode, externalId string, opts Options) (Asset, []Verdict, error) {
+	c := &comparer{
+		index:        newKnownIndex(knownVulns),
+		externalId:   externalId,
+		suppressions: opts.Suppressions,
+		templates:    opts.Templates,
+		asset: Asset{
+			VulnerabilityFindings: make([]VulnerabilityFinding, 0),
+		},
+		verdicts:  make([]Verdict, 0),
+		legacyIDs: make(map[string]bool),
 	}
 
 	for _, lib := range scanResult.Libraries {
-		detectionMethod := lib.DetectionMethod
 		for _, vuln := range lib.Vulnerabilities {
-			// Then, compare for known vulnerabilities
-			ignoreVuln := false
-			newUUID := uuid.New()
-			id := newUUID.String()
-			vulnCompare := ""
-			path := ""
-			var err error
-			for _, kv := range knownVulns {
-				path, err = extractPath(kv.Description)
-				if err != nil {
-					path = ""
-				}
-				//if !strings.HasPrefix(kv.ID, "WIZCLI") {
-				if kv.DataSourceName == "" {
-					// We are now comparing vulns found by Wiz disk scanner
-					if vuln.Name == kv.Name && lib.Name == kv.DetailedName && vuln.FixedVersion == kv.FixedVersion && lib.DetectionMethod == kv.DetectionMethod && lib.Path == path {
-						// Match says it's an existing vuln from Wiz disk scanner so, ignore
-						vulnCompare = fmt.Sprintf("%s\n\tID: %s\n\tType: %s\n\tLibrary: %s\n\tPath: %s\n\tVersion: %s\n\tVerdict: %s\n\n", vuln.Name, kv.ID, "Library", lib.Name, lib.Path, lib.Version, "Ignore")
-						id = kv.ID
-						ignoreVuln = true
-					}
-					//} else if strings.HasPrefix(kv.ID, "WIZCLI") {
-				} else if kv.DataSourceName == "WizCLI" {
-					// We are now comparing vulns found by wizcli scanner
-					if vuln.Name == kv.Name && lib.Name == kv.DetailedName && lib.DetectionMethod == kv.DetectionMethod {
-						vulnCompare = fmt.Sprintf("%s\n\tID: %s\n\tType: %s\n\tLibrary: %s\n\tPath: %s\n\tVersion: %s\n\tVerdict: %s\n\n", vuln.Name, kv.ID, "Library", lib.Name, lib.Path, lib.Version, "Keep")
-						//id = kv.ID
-						id = fmt.Sprintf("%s-%s-%s", externalId, vuln.Name, lib.Name)
-					}
-				}
-				// If vulnCompare is empty and ignoreVuln is set to false, then we need to add Wiz Platform
-				if vulnCompare == "" && !ignoreVuln {
-					id = fmt.Sprintf("%s-%s-%s", externalId, vuln.Name, lib.Name)
-					vulnCompare = fmt.Sprintf("%s\n\tID: %s\n\tType: %s\n\tLibrary: %s\n\tPath: %s\n\tVersion: %s\n\tVerdict: %s\n\n", vuln.Name, id, "Library", lib.Name, lib.Path, lib.Version, "Add")
-				}
-			}
-			//fmt.Println(vulnCompare)
-			if !ignoreVuln {
-				// If ignoreVuln is set to false, then we will want to update the Wiz graph
-				description := ""
-				if lib.Path == "" {
-					description = fmt.Sprintf("The library `%s` version `%s` is vulnerable to `%s`", lib.Name, lib.Version, vuln.Name)
-				} else {
-					description = fmt.Sprintf("The library `%s` version `%s` located at `%s` is vulnerable to `%s`", lib.Name, lib.Version, lib.Path, vuln.Name)
-				}
-				if vuln.FixedVersion != "" {
-					description += fmt.Sprintf(", which exists in versions less than `%s`", vuln.FixedVersion)
-				}
-				description += fmt.Sprintf(".\nThe vulnerability was found at `%s` with vendor severity of: `%s`.\n", vuln.Source, vuln.Severity)
-				if vuln.FixedVersion != "" {
-					description += fmt.Sprintf("The vulnerability can be remediated by updating the library to version `%s` or higher.", vuln.FixedVersion)
-				} else {
-					description += "At this time there is not a fix for this vulnerability."
-				}
-				normalizedSeverity := normalizeAndValidateSeverity(vuln.Severity)
-				vulnerability := VulnerabilityFinding{
-					Id:                      id,
-					Name:                    vuln.Name,
-					DetailedName:            lib.Name,
-					ExternalDetectionSource: normalizeAndValidateDataSource(detectionMethod),
-					Severity:                normalizedSeverity,
-					ExternalFindingLink:     vuln.Source,
-					Version:                 lib.Version,
-					Source:                  "WizCLI",
-					FixedVersion:            vuln.FixedVersion,
-					Remediation:             vuln.FixedVersion,
-					ValidatedAtRuntime:      false,
-					Description:             description,
-				}
-				assetVulns.VulnerabilityFindings = append(assetVulns.VulnerabilityFindings, vulnerability)
-			}
+			c.compareLibrary(lib, vuln)
 		}
 	}
 
 	for _, app := range scanResult.Applications {
-		detectionMethod := app.DetectionMethod
 		for _, vuln := range app.Vulnerabilities {
-			// Then, compare for known vulnerabilities
-			ignoreVuln := false
-			newUUID := uuid.New()
-			id := "WIZCLI-" + newUUID.String()
-			vulnCompare := ""
-			path := ""
-			var err error
-			for _, kv := range knownVulns {
-				path, err = extractPath(kv.Description)
-				if err != nil {
-					path = ""
-				}
-				if !strings.HasPrefix(kv.ID, "WIZCLI") {
-					// We are now comparing vulns found by Wiz disk scanner
-					if vuln.Vulnerability.Name == kv.Name && app.Name == kv.DetailedName && vuln.Vulnerability.FixedVersion == kv.FixedVersion && app.DetectionMethod == kv.DetectionMethod {
-						// Match says it's an existing vuln from Wiz disk scanner so, ignore
-						vulnCompare = fmt.Sprintf("%s\n\tID: %s\n\tType: %s\n\tApplication: %s\n\tVersion: %s\n\tVerdict: %s\n\n", vuln.Vulnerability.Name, kv.ID, "Application", app.Name, vuln.Version, "Ignore")
-						id = kv.ID
-						ignoreVuln = true
-					}
-				} else if strings.HasPrefix(kv.ID, "WIZCLI") {
-					// We are now comparing vulns found by wizcli scanner
-					if vuln.Vulnerability.Name == kv.Name && app.Name == kv.DetailedName && vuln.Vulnerability.FixedVersion == kv.FixedVersion && app.DetectionMethod == kv.DetectionMethod {
-						vulnCompare = fmt.Sprintf("EXISTING VULN\n%s found in application %s, in version %s\n\n", vuln.Vulnerability.Name, app.Name, vuln.Version)
-						id = kv.ID
-					}
-				}
-				// If vulnCompare is empty and ignoreVuln is set to false, then we need to add Wiz Platform
-				if vulnCompare == "" && !ignoreVuln {
-					vulnCompare = fmt.Sprintf("%s\n\tID: %s\n\tType: %s\n\tApplication: %s\n\tVersion: %s\n\tVerdict: %s\n\n", vuln.Vulnerability.Name, id, "Application", app.Name, vuln.Version, "Add")
-				}
-			}
-
-			if !ignoreVuln {
-				normalizedSeverity := normalizeAndValidateSeverity(vuln.Vulnerability.Severity)
-				vulnerability := VulnerabilityFinding{
-					Id:                      id,
-					Name:                    vuln.Vulnerability.Name,
-					DetailedName:            app.Name,
-					ExternalDetectionSource: normalizeAndValidateDataSource(detectionMethod),
-					Severity:                normalizedSeverity,
-					ExternalFindingLink:     vuln.Vulnerability.Source,
-					Version:                 vuln.Version,
-					Source:                  "WizCLI",
-					Remediation:             vuln.Vulnerability.FixedVersion,
-					ValidatedAtRuntime:      false,
-					Description:             path,
-				}
-				assetVulns.VulnerabilityFindings = append(assetVulns.VulnerabilityFindings, vulnerability)
-			}
+			c.compareApplication(app, vuln)
+		}
+	}
+
+	osEcosystem, _ := ecosystem.DetectOS()
+	for _, pkg := range scanResult.OsPackages {
+		for _, vuln := range pkg.Vulnerabilities {
+			c.compareOs(pkg.Name, pkg.Version, "", pkg.DetectionMethod, osEcosystem, vuln)
+		}
+	}
+
+	for _, cpe := range scanResult.Cpes {
+		for _, vuln := range cpe.Vulnerabilities {
+			c.compareOs(cpe.Name, cpe.Version, cpe.Path, cpe.DetectionMethod, ecosystem.Unknown, vuln)
+		}
+	}
+
+	return c.asset, c.verdicts, nil
+}
+
+// ignore records a vulnerability that won't be uploaded, with the Wiz finding that already reports it if any.
+func (c *comparer) ignore(verdict Verdict, wizFindingID, reason string) {
+	verdict.Action = ActionIgnore
+	verdict.Reason = reason
+	verdict.WizFindingID = wizFindingID
+	c.verdicts = append(c.verdicts, verdict)
+}
+
+// alreadyFixed ignores the vulnerability when the installed version is not below the fixed version.
+func (c *comparer) alreadyFixed(verdict Verdict, eco ecosystem.Ecosystem) bool {
+	if !ecosystem.IsFixed(eco, verdict.Version, verdict.FixedVersion) {
+		return false
+	}
+	verdict.AlreadyFixed = true
+	c.ignore(verdict, "", fmt.Sprintf("installed version %s is not below the fixed version %s", verdict.Version, verdict.FixedVersion))
+	return true
+}
+
+// upload records a vulnerability that will be uploaded as finding, unless a suppression covers it.
+// Suppressed versions are compared by the rules of eco.
+func (c *comparer) upload(verdict Verdict, eco ecosystem.Ecosystem, finding VulnerabilityFinding) {
+	active, expired := c.suppressions.Match(verdict.CVE, verdict.Package, verdict.Version, verdict.Path, eco)
+	if active != nil {
+		verdict.Action = ActionSuppress
+		verdict.Reason = active.Reason()
+		c.verdicts = append(c.verdicts, verdict)
+		return
+	}
+	if expired != nil {
+		verdict.Reason += fmt.Sprintf("; suppression in %s expired %s", expired.Source, expired.Expires)
+	}
+	finding.Path = verdict.Path
+	c.verdicts = append(c.verdicts, verdict)
+	c.asset.VulnerabilityFindings = append(c.asset.VulnerabilityFindings, finding)
+}
+
+// classify sets the Add or Keep action depending on whether wiz-scan uploaded the vulnerability before.
+// A finding uploaded under a legacy ID keeps that ID, once, as legacy IDs didn't tell paths apart.
+func (c *comparer) classify(verdict *Verdict, previous *knownFinding) {
+	if previous != nil {
+		verdict.Action = ActionKeep
+		verdict.Reason = "previously uploaded by wiz-scan, uploaded again to keep it current"
+		verdict.WizFindingID = previous.ID
+		if !c.legacyIDs[previous.ID] && isLegacyFindingID(previous.ID, c.externalId, verdict.CVE, verdict.Package) {
+			c.legacyIDs[previous.ID] = true
+			verdict.FindingID = previous.ID
 		}
+		return
+	}
+	verdict.Action = ActionAdd
+	verdict.Reason = "not reported by Wiz"
+}
+
+func (c *comparer) compareLibrary(lib wizcli.Library, vuln wizcli.Vulnerability) {
+	detectionMethod := lib.DetectionMethod
+	verdict := Verdict{
+		Type:         TypeLibrary,
+		CVE:          vuln.Name,
+		Package:      lib.Name,
+		Version:      lib.Version,
+		FixedVersion: vuln.FixedVersion,
+		Path:         lib.Path,
+		Severity:     normalizeAndValidateSeverity(vuln.Severity),
+		Risk:         riskAttributes(vuln),
+		FindingID:    FindingID(c.externalId, vuln.Name, lib.Name, lib.Version, lib.Path, detectionMethod),
+	}
+
+	// Only drop the vulnerability as fixed when the ecosystem isn't a guess
+	eco := ecosystem.DetectLibrary(lib.Name, lib.Path)
+	if c.alreadyFixed(verdict, ecosystem.KnownLibraryEcosystem(lib.Name, lib.Path)) {
+		return
+	}
+
+	// A match from the Wiz disk scanner at the same path says it's an existing vuln, so ignore
+	if kv := c.index.find(vuln.Name, lib.Name, lib.Path, detectionMethod, true, func(kv *knownFinding) bool {
+		return kv.DataSourceName == "" && ecosystem.Equal(eco, vuln.FixedVersion, kv.FixedVersion)
+	}); kv != nil {
+		c.ignore(verdict, kv.ID, "reported by the Wiz disk scanner at the same path")
+		return
+	}
+	c.classify(&verdict, c.index.find(vuln.Name, lib.Name, "", detectionMethod, false, (*knownFinding).isWizCLI))
+
+	// Not ignored, so we will want to update the Wiz graph
+	description, remediation := c.templates.render(templateData(verdict, vuln, eco))
+
+	c.upload(verdict, eco, VulnerabilityFinding{
+		Id:                      verdict.FindingID,
+		Name:                    vuln.Name,
+		DetailedName:            lib.Name,
+		ExternalDetectionSource: normalizeAndValidateDataSource(detectionMethod),
+		Severity:                verdict.Severity,
+		ExternalFindingLink:     vuln.Source,
+		Version:                 lib.Version,
+		Source:                  "WizCLI",
+		FixedVersion:            vuln.FixedVersion,
+		Remediation:             remediation,
+		ValidatedAtRuntime:      false,
+		RiskAttributes:          verdict.Risk,
+		Description:             description,
+	})
+}
+
+func (c *comparer) compareApplication(app wizcli.Applications, detail wizcli.VulnerabilityDetail) {
+	detectionMethod := app.DetectionMethod
+	vuln := detail.Vulnerability
+	path, _ := detail.Path.(string)
+	verdict := Verdict{
+		Type:         TypeApplication,
+		CVE:          vuln.Name,
+		Package:      app.Name,
+		Version:      detail.Version,
+		FixedVersion: vuln.FixedVersion,
+		Path:         path,
+		Severity:     normalizeAndValidateSeverity(vuln.Severity),
+		Risk:         riskAttributes(vuln),
+		FindingID:    FindingID(c.externalId, vuln.Name, app.Name, detail.Version, path, detectionMethod),
+	}
+
+	// Applications don't belong to a package ecosystem, so only the generic version rules apply
+	eco := ecosystem.Unknown
+
+	// Match from anything but wiz-scan says it's an existing vuln, so ignore
+	if kv := c.index.find(vuln.Name, app.Name, "", detectionMethod, false, func(kv *knownFinding) bool {
+		return !strings.HasPrefix(kv.ID, findingIDPrefix) && ecosystem.Equal(eco, vuln.FixedVersion, kv.FixedVersion)
+	}); kv != nil {
+		c.ignore(verdict, kv.ID, "reported by Wiz for the same application")
+		return
 	}
+	c.classify(&verdict, c.index.find(vuln.Name, app.Name, "", detectionMethod, false, func(kv *knownFinding) bool {
+		return strings.HasPrefix(kv.ID, findingIDPrefix) && ecosystem.Equal(eco, vuln.FixedVersion, kv.FixedVersion)
+	}))
 
-	return assetVulns, nil
+	description, remediation := c.templates.render(templateData(verdict, vuln, eco))
 
+	c.upload(verdict, eco, VulnerabilityFinding{
+		Id:                      verdict.FindingID,
+		Name:                    vuln.Name,
+		DetailedName:            app.Name,
+		ExternalDetectionSource: normalizeAndValidateDataSource(detectionMethod),
+		Severity:                verdict.Severity,
+		ExternalFindingLink:     vuln.Source,
+		Version:                 detail.Version,
+		Source:                  "WizCLI",
+		Remediation:             remediation,
+		ValidatedAtRuntime:      false,
+		RiskAttributes:          verdict.Risk,
+		Description:             description,
+	})
+}
+
+// compareOs compares a vulnerability of an OS package or CPE, which is reported with the "OS" detection source.
+// OS packages follow the versioning of the host's package manager while CPEs have no ecosystem.
+func (c *comparer) compareOs(name, version, path, detectionMethod string, eco ecosystem.Ecosystem, vuln wizcli.Vulnerability) {
+	verdict := Verdict{
+		Type:         TypeOS,
+		CVE:          vuln.Name,
+		Package:      name,
+		Version:      version,
+		FixedVersion: vuln.FixedVersion,
+		Path:         path,
+		Severity:     normalizeAndValidateSeverity(vuln.Severity),
+		Risk:         riskAttributes(vuln),
+		FindingID:    FindingID(c.externalId, vuln.Name, name, version, path, detectionMethod),
+	}
+
+	if c.alreadyFixed(verdict, eco) {
+		return
+	}
+
+	// Only the Wiz disk scanner findings can make a wizcli finding redundant
+	if kv := c.index.find(vuln.Name, name, "", detectionMethod, false, func(kv *knownFinding) bool {
+		return kv.DataSourceName == "" && ecosystem.Equal(eco, vuln.FixedVersion, kv.FixedVersion)
+	}); kv != nil {
+		c.ignore(verdict, kv.ID, "reported by the Wiz disk scanner")
+		return
+	}
+	c.classify(&verdict, c.index.find(vuln.Name, name, "", detectionMethod, false, (*knownFinding).isWizCLI))
+
+	description, remediation := c.templates.render(templateData(verdict, vuln, eco))
+
+	c.upload(verdict, eco, VulnerabilityFinding{
+		Id:                      verdict.FindingID,
+		Name:                    vuln.Name,
+		DetailedName:            name,
+		ExternalDetectionSource: "OS",
+		Severity:                verdict.Severity,
+		ExternalFindingLink:     vuln.Source,
+		Version:                 version,
+		Source:                  "WizCLI",
+		FixedVersion:            vuln.FixedVersion,
+		Remediation:             remediation,
+		ValidatedAtRuntime:      false,
+		RiskAttributes:          verdict.Risk,
+		Description:             description,
+	})
+}
+
+// templateData collects what the description and remediation templates can refer to.
+func templateData(verdict Verdict, vuln wizcli.Vulnerability, eco ecosystem.Ecosystem) TemplateData {
+	return TemplateData{
+		Type:           verdict.Type,
+		CVE:            verdict.CVE,
+		Package:        verdict.Package,
+		Version:        verdict.Version,
+		FixedVersion:   verdict.FixedVersion,
+		Path:           verdict.Path,
+		Severity:       verdict.Severity,
+		VendorSeverity: vuln.Severity,
+		Source:         vuln.Source,
+		Ecosystem:      string(eco),
+		Upgrade:        upgradeGuidance(eco, verdict.Package, verdict.FixedVersion),
+	}
 }
 
 func extractPath(str string) (string, error) {
-	re := regexp.MustCompile(`located at (.*?) and is vulnerable to`)
-	matches := re.FindStringSubmatch(str)
+	matches := knownPathPattern.FindStringSubmatch(str)
 
 	if len(matches) > 1 {
 		trimmedPath := strings.Trim(matches[1], "`") // Remove backticks from start and end
@@ -229,6 +368,9 @@ func normalizeAndValidateSeverity(input string) string {
 }
 
 func normalizeAndValidateDataSource(input string) string {
+	if input == "" {
+		return "" // Left empty for Validate to report instead of panicking here
+	}
 	if input == "OS" {
 		return input // Return input unchanged if it's "OS"
 	}
@@ -0,0 +1,267 @@
+package vulnerability
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jtb75/wiz-scan/pkg/wizapi"
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// legacyOutcome is what the linear comparison reported for one scanned vulnerability.
+type legacyOutcome struct {
+	ignored      bool
+	previous     bool
+	wizFindingID string
+}
+
+// legacyCompare is the linear scan over every known finding that the index replaced, kept
+// to check the index still reaches the same verdicts.
+func legacyCompare(scanResult wizcli.AggregatedScanResults, knownVulns []wizapi.VulnerabilityNode) map[string]legacyOutcome {
+	outcomes := make(map[string]legacyOutcome)
+
+	for _, lib := range scanResult.Libraries {
+		for _, vuln := range lib.Vulnerabilities {
+			var outcome legacyOutcome
+			for _, kv := range knownVulns {
+				path, err := extractPath(kv.Description)
+				if err != nil {
+					path = ""
+				}
+				if kv.DataSourceName == "" {
+					if vuln.Name == kv.Name && lib.Name == kv.DetailedName && vuln.FixedVersion == kv.FixedVersion && lib.DetectionMethod == kv.DetectionMethod && lib.Path == path {
+						outcome = legacyOutcome{ignored: true, wizFindingID: kv.ID}
+					}
+				} else if kv.DataSourceName == "WizCLI" && !outcome.ignored {
+					if vuln.Name == kv.Name && lib.Name == kv.DetailedName && lib.DetectionMethod == kv.DetectionMethod {
+						outcome.previous = true
+					}
+				}
+			}
+			outcomes[legacyKey(TypeLibrary, vuln.Name, lib.Name, lib.Path)] = outcome
+		}
+	}
+
+	for _, app := range scanResult.Applications {
+		for _, detail := range app.Vulnerabilities {
+			vuln := detail.Vulnerability
+			var outcome legacyOutcome
+			for _, kv := range knownVulns {
+				if vuln.Name != kv.Name || app.Name != kv.DetailedName || vuln.FixedVersion != kv.FixedVersion || app.DetectionMethod != kv.DetectionMethod {
+					continue
+				}
+				if !strings.HasPrefix(kv.ID, "WIZCLI") {
+					outcome = legacyOutcome{ignored: true, wizFindingID: kv.ID}
+				} else if !outcome.ignored {
+					outcome.previous = true
+				}
+			}
+			path, _ := detail.Path.(string)
+			outcomes[legacyKey(TypeApplication, vuln.Name, app.Name, path)] = outcome
+		}
+	}
+
+	for _, pkg := range scanResult.OsPackages {
+		for _, vuln := range pkg.Vulnerabilities {
+			var outcome legacyOutcome
+			for _, kv := range knownVulns {
+				if kv.DataSourceName == "" && vuln.Name == kv.Name && pkg.Name == kv.DetailedName && vuln.FixedVersion == kv.FixedVersion && pkg.DetectionMethod == kv.DetectionMethod {
+					outcome = legacyOutcome{ignored: true, wizFindingID: kv.ID}
+					break
+				}
+			}
+			outcomes[legacyKey(TypeOS, vuln.Name, pkg.Name, "")] = outcome
+		}
+	}
+
+	return outcomes
+}
+
+func legacyKey(kind, cve, pkg, path string) string {
+	return strings.Join([]string{kind, cve, pkg, path}, "|")
+}
+
+func diskFinding(id, cve, pkg, fixed, method, path string) wizapi.VulnerabilityNode {
+	description := ""
+	if path != "" {
+		description = fmt.Sprintf("The library `%s` located at `%s` and is vulnerable to `%s`", pkg, path, cve)
+	}
+	return wizapi.VulnerabilityNode{ID: id, Name: cve, DetailedName: pkg, FixedVersion: fixed, DetectionMethod: method, Description: description}
+}
+
+func TestCompareVulnerabilitiesMatchesLinearScan(t *testing.T) {
+	vuln := func(cve, fixed string) wizcli.Vulnerability {
+		return wizcli.Vulnerability{Name: cve, FixedVersion: fixed, Severity: "HIGH"}
+	}
+
+	tests := []struct {
+		name  string
+		scan  wizcli.AggregatedScanResults
+		known []wizapi.VulnerabilityNode
+	}{
+		{
+			name: "library reported by the disk scanner at the same path",
+			scan: wizcli.AggregatedScanResults{Libraries: []wizcli.Library{
+				{Name: "org.apache.logging.log4j:log4j-core", Version: "2.14.1", Path: "/opt/app/log4j-core-2.14.1.jar", DetectionMethod: "LIBRARY", Vulnerabilities: []wizcli.Vulnerability{vuln("CVE-2021-44228", "2.15.0")}},
+			}},
+			known: []wizapi.VulnerabilityNode{diskFinding("disk-1", "CVE-2021-44228", "org.apache.logging.log4j:log4j-core", "2.15.0", "LIBRARY", "/opt/app/log4j-core-2.14.1.jar")},
+		},
+		{
+			name: "library reported by the disk scanner at another path",
+			scan: wizcli.AggregatedScanResults{Libraries: []wizcli.Library{
+				{Name: "org.apache.logging.log4j:log4j-core", Version: "2.14.1", Path: "/srv/other/log4j-core-2.14.1.jar", DetectionMethod: "LIBRARY", Vulnerabilities: []wizcli.Vulnerability{vuln("CVE-2021-44228", "2.15.0")}},
+			}},
+			known: []wizapi.VulnerabilityNode{diskFinding("disk-1", "CVE-2021-44228", "org.apache.logging.log4j:log4j-core", "2.15.0", "LIBRARY", "/opt/app/log4j-core-2.14.1.jar")},
+		},
+		{
+			name: "library uploaded by an earlier run",
+			scan: wizcli.AggregatedScanResults{Libraries: []wizcli.Library{
+				{Name: "lodash", Version: "4.17.20", Path: "/srv/web/node_modules/lodash/package.json", DetectionMethod: "LIBRARY", Vulnerabilities: []wizcli.Vulnerability{vuln("CVE-2021-23337", "4.17.21")}},
+			}},
+			known: []wizapi.VulnerabilityNode{{ID: "WIZCLI-1", Name: "CVE-2021-23337", DetailedName: "lodash", FixedVersion: "4.17.21", DetectionMethod: "LIBRARY", DataSourceName: "WizCLI"}},
+		},
+		{
+			name: "application reported by Wiz and by an earlier run",
+			scan: wizcli.AggregatedScanResults{Applications: []wizcli.Applications{
+				{Name: "nginx", DetectionMethod: "FILE_PATH", Vulnerabilities: []wizcli.VulnerabilityDetail{
+					{Path: "/usr/sbin/nginx", Version: "1.20.0", Vulnerability: vuln("CVE-2021-23017", "1.20.1")},
+					{Path: "/usr/sbin/nginx", Version: "1.20.0", Vulnerability: vuln("CVE-2022-41741", "1.23.2")},
+					{Path: "/usr/sbin/nginx", Version: "1.20.0", Vulnerability: vuln("CVE-2023-44487", "1.25.3")},
+				}},
+			}},
+			known: []wizapi.VulnerabilityNode{
+				diskFinding("disk-3", "CVE-2021-23017", "nginx", "1.20.1", "FILE_PATH", ""),
+				{ID: "WIZCLI-2", Name: "CVE-2022-41741", DetailedName: "nginx", FixedVersion: "1.23.2", DetectionMethod: "FILE_PATH", DataSourceName: "WizCLI"},
+			},
+		},
+		{
+			name: "OS package reported by the disk scanner",
+			scan: wizcli.AggregatedScanResults{OsPackages: []wizcli.OsPackage{
+				{Name: "openssl", Version: "1.1.1n-0+deb11u3", DetectionMethod: "OS", Vulnerabilities: []wizcli.Vulnerability{vuln("CVE-2023-0286", "1.1.1n-0+deb11u4"), vuln("CVE-2023-0215", "1.1.1n-0+deb11u4")}},
+			}},
+			known: []wizapi.VulnerabilityNode{diskFinding("disk-4", "CVE-2023-0286", "openssl", "1.1.1n-0+deb11u4", "OS", "")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := legacyCompare(tt.scan, tt.known)
+			_, verdicts, err := CompareVulnerabilities(tt.scan, tt.known, "i-0123456789", Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(verdicts) != len(want) {
+				t.Fatalf("got %d verdicts, want %d", len(verdicts), len(want))
+			}
+			for _, verdict := range verdicts {
+				key := legacyKey(verdict.Type, verdict.CVE, verdict.Package, verdict.Path)
+				outcome, ok := want[key]
+				if !ok {
+					t.Fatalf("unexpected verdict for %s", key)
+				}
+				if ignored := verdict.Action == ActionIgnore; ignored != outcome.ignored {
+					t.Errorf("%s: got %s, linear scan ignored: %v", key, verdict.Action, outcome.ignored)
+				}
+				if outcome.ignored && verdict.WizFindingID != outcome.wizFindingID {
+					t.Errorf("%s: matched %q, linear scan matched %q", key, verdict.WizFindingID, outcome.wizFindingID)
+				}
+				// The linear scan never looked for earlier uploads of OS packages
+				if !outcome.ignored && verdict.Type != TypeOS && (verdict.Action == ActionKeep) != outcome.previous {
+					t.Errorf("%s: got %s, linear scan found an earlier upload: %v", key, verdict.Action, outcome.previous)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareVulnerabilitiesNormalizesKeys(t *testing.T) {
+	vuln := func(cve, fixed string) wizcli.Vulnerability {
+		return wizcli.Vulnerability{Name: cve, FixedVersion: fixed, Severity: "HIGH"}
+	}
+	scan := wizcli.AggregatedScanResults{
+		Libraries: []wizcli.Library{
+			{Name: "Lodash", Version: "4.17.20", Path: "/srv/web//node_modules/./lodash/package.json", DetectionMethod: "LIBRARY", Vulnerabilities: []wizcli.Vulnerability{vuln("cve-2021-23337", "4.17.21")}},
+			{Name: "org.apache.logging.log4j:log4j-core", Version: "2.14.1", Path: `C:\app\lib\log4j-core-2.14.1.jar`, DetectionMethod: "LIBRARY", Vulnerabilities: []wizcli.Vulnerability{vuln("CVE-2021-44228", "2.15.0")}},
+			{Name: "minimist", Version: "1.2.5", Path: "/srv/web/node_modules/minimist/package.json", DetectionMethod: "LIBRARY", Vulnerabilities: []wizcli.Vulnerability{vuln("CVE-2021-44906", "1.2.6")}},
+		},
+		OsPackages: []wizcli.OsPackage{
+			{Name: "OpenSSL", Version: "1.1.1n-0+deb11u3", DetectionMethod: "OS", Vulnerabilities: []wizcli.Vulnerability{vuln("CVE-2023-0286", "1.1.1n-0+deb11u4")}},
+		},
+	}
+	known := []wizapi.VulnerabilityNode{
+		diskFinding("disk-1", "CVE-2021-23337", "lodash", "4.17.21", "LIBRARY", "/srv/web/node_modules/lodash/package.json"),
+		diskFinding("disk-2", "CVE-2021-44228", "org.apache.logging.log4j:log4j-core", "2.15.0", "LIBRARY", "C:/app/lib/log4j-core-2.14.1.jar"),
+		diskFinding("disk-3", "CVE-2021-44906", "minimist", "1.2.6", "LIBRARY", "/srv/other/node_modules/minimist/package.json"),
+		diskFinding("disk-4", "cve-2023-0286", "openssl", "1.1.1n-0+deb11u4", "os", ""),
+	}
+
+	_, verdicts, err := CompareVulnerabilities(scan, known, "i-0123456789", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"CVE-2021-23337": "disk-1",
+		"CVE-2021-44228": "disk-2",
+		"CVE-2021-44906": "",
+		"CVE-2023-0286":  "disk-4",
+	}
+	if len(verdicts) != len(want) {
+		t.Fatalf("got %d verdicts, want %d", len(verdicts), len(want))
+	}
+	for _, verdict := range verdicts {
+		id, ok := want[strings.ToUpper(verdict.CVE)]
+		if !ok {
+			t.Fatalf("unexpected verdict for %s", verdict.CVE)
+		}
+		if id == "" {
+			// Normalizing the path doesn't match a finding at another path
+			if verdict.Action == ActionIgnore {
+				t.Errorf("%s matched %s at another path", verdict.CVE, verdict.WizFindingID)
+			}
+		} else if verdict.Action != ActionIgnore || verdict.WizFindingID != id {
+			t.Errorf("%s: got %s matching %q, want it ignored as %s", verdict.CVE, verdict.Action, verdict.WizFindingID, id)
+		}
+	}
+}
+
+// benchmarkScan builds scan results of libraries and the findings Wiz knows about, a third of
+// them from the disk scanner and a third from earlier wiz-scan runs.
+func benchmarkScan(libraries int) (wizcli.AggregatedScanResults, []wizapi.VulnerabilityNode) {
+	var scan wizcli.AggregatedScanResults
+	var known []wizapi.VulnerabilityNode
+	for i := 0; i < libraries; i++ {
+		name := fmt.Sprintf("com.example:lib-%d", i)
+		path := fmt.Sprintf("/opt/app/lib/lib-%d-1.0.0.jar", i)
+		cve := fmt.Sprintf("CVE-2024-%05d", i)
+		scan.Libraries = append(scan.Libraries, wizcli.Library{
+			Name: name, Version: "1.0.0", Path: path, DetectionMethod: "LIBRARY",
+			Vulnerabilities: []wizcli.Vulnerability{{Name: cve, FixedVersion: "1.0.1", Severity: "MEDIUM"}},
+		})
+		switch i % 3 {
+		case 0:
+			known = append(known, diskFinding(fmt.Sprintf("disk-%d", i), cve, name, "1.0.1", "LIBRARY", path))
+		case 1:
+			known = append(known, wizapi.VulnerabilityNode{ID: fmt.Sprintf("WIZCLI-%d", i), Name: cve, DetailedName: name, FixedVersion: "1.0.1", DetectionMethod: "LIBRARY", DataSourceName: "WizCLI"})
+		}
+	}
+	return scan, known
+}
+
+func BenchmarkCompareVulnerabilities(b *testing.B) {
+	scan, known := benchmarkScan(1800)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := CompareVulnerabilities(scan, known, "i-0123456789", Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLinearCompare(b *testing.B) {
+	scan, known := benchmarkScan(1800)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		legacyCompare(scan, known)
+	}
+}
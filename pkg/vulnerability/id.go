@@ -0,0 +1,95 @@
+package vulnerability
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// findingIDPrefix marks findings uploaded by wiz-scan so later runs can recognise them.
+const findingIDPrefix = "WIZCLI-"
+
+// FindingID returns the deterministic ID of a finding uploaded by wiz-scan.
+//
+// The ID is "WIZCLI-" followed by the first 32 hex characters of the SHA-256 of
+// the provider ID, CVE, package name, version-independent path and detection
+// method, joined by NUL bytes. Every part is trimmed and lower-cased and the path
+// uses forward slashes, so the same finding gets the same ID across runs, hosts
+// and tool versions. The installed version is removed from the path so a package
+// upgraded to another vulnerable version keeps its ID and is updated in place.
+func FindingID(providerID, cve, pkg, version, path, detectionMethod string) string {
+	parts := []string{
+		providerID,
+		cve,
+		pkg,
+		versionIndependentPath(path, version),
+		detectionMethod,
+	}
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(part))
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return findingIDPrefix + hex.EncodeToString(sum[:16])
+}
+
+// versionIndependentPath normalizes path separators and drops the installed version
+// from the file name, e.g. /opt/app/lib/log4j-core-2.14.1.jar becomes /opt/app/lib/log4j-core-.jar.
+// Only whole tokens are removed, delimited by the start or end of the name or one of
+// "-", "_", "." and "@", so a version of 3.1 leaves python3.11 alone and the directories,
+// where a match would make /opt/app1/lib-1.jar and /opt/app/lib-1.jar collide, are kept.
+func versionIndependentPath(path, version string) string {
+	path = strings.ReplaceAll(path, "\\", "/")
+	if version == "" {
+		return path
+	}
+	dir, name := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir, name = path[:i+1], path[i+1:]
+	}
+
+	var b strings.Builder
+	for {
+		i := tokenIndex(name, version)
+		if i < 0 {
+			break
+		}
+		b.WriteString(name[:i])
+		name = name[i+len(version):]
+	}
+	b.WriteString(name)
+	return dir + b.String()
+}
+
+// tokenIndex returns the index of the first occurrence of version in name that stands
+// as a token of its own, or -1.
+func tokenIndex(name, version string) int {
+	for offset := 0; offset < len(name); {
+		i := strings.Index(name[offset:], version)
+		if i < 0 {
+			return -1
+		}
+		start, end := offset+i, offset+i+len(version)
+		if (start == 0 || isVersionDelimiter(name[start-1])) && (end == len(name) || isVersionDelimiter(name[end])) {
+			return start
+		}
+		offset = start + 1
+	}
+	return -1
+}
+
+func isVersionDelimiter(c byte) bool {
+	return c == '-' || c == '_' || c == '.' || c == '@'
+}
+
+// isLegacyFindingID reports whether id was given to a finding by a wiz-scan version from
+// before deterministic IDs: "<providerID>-<CVE>-<package>" for libraries and OS packages,
+// or "WIZCLI-" followed by a random UUID for applications. Such findings keep their ID
+// so Wiz goes on updating them in place instead of reporting them twice.
+func isLegacyFindingID(id, providerID, cve, pkg string) bool {
+	if id == providerID+"-"+cve+"-"+pkg {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(id, findingIDPrefix)
+	return ok && strings.Count(suffix, "-") == 4
+}
@@ -0,0 +1,115 @@
+package vulnerability
+
+import (
+	"testing"
+
+	"github.com/jtb75/wiz-scan/pkg/wizapi"
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+func TestVersionIndependentPath(t *testing.T) {
+	tests := []struct {
+		path, version, want string
+	}{
+		{"/opt/app/lib/log4j-core-2.14.1.jar", "2.14.1", "/opt/app/lib/log4j-core-.jar"},
+		{`C:\app\lib\log4j-core-2.14.1.jar`, "2.14.1", "C:/app/lib/log4j-core-.jar"},
+		{"/srv/web/node_modules/lodash@4.17.20/package.json", "4.17.20", "/srv/web/node_modules/lodash@4.17.20/package.json"},
+		{"/srv/app/lodash@4.17.20", "4.17.20", "/srv/app/lodash@"},
+		{"/usr/lib/python3.11/site-packages/foo_3.1.dist-info", "3.1", "/usr/lib/python3.11/site-packages/foo_.dist-info"},
+		{"/usr/bin/python3.11", "3.1", "/usr/bin/python3.11"},
+		{"/opt/app1/lib-1.jar", "1", "/opt/app1/lib-.jar"},
+		{"/opt/lib-1.jar", "", "/opt/lib-1.jar"},
+		{"/opt/1/1", "1", "/opt/1/"},
+	}
+	for _, tt := range tests {
+		if got := versionIndependentPath(tt.path, tt.version); got != tt.want {
+			t.Errorf("versionIndependentPath(%q, %q) = %q, want %q", tt.path, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestFindingIDCollisions(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b [6]string
+		same bool
+	}{
+		{
+			name: "version in a directory name",
+			a:    [6]string{"i-1", "CVE-2024-1", "lib", "1", "/opt/app1/lib-1.jar", "LIBRARY"},
+			b:    [6]string{"i-1", "CVE-2024-1", "lib", "1", "/opt/app/lib-1.jar", "LIBRARY"},
+		},
+		{
+			name: "version inside another token",
+			a:    [6]string{"i-1", "CVE-2024-1", "python", "3.1", "/usr/bin/python3.11", "FILE_PATH"},
+			b:    [6]string{"i-1", "CVE-2024-1", "python", "3.1", "/usr/bin/python1", "FILE_PATH"},
+		},
+		{
+			name: "different paths",
+			a:    [6]string{"i-1", "CVE-2024-1", "lib", "1.0", "/opt/a/lib-1.0.jar", "LIBRARY"},
+			b:    [6]string{"i-1", "CVE-2024-1", "lib", "1.0", "/opt/b/lib-1.0.jar", "LIBRARY"},
+		},
+		{
+			name: "upgraded to another vulnerable version",
+			a:    [6]string{"i-1", "CVE-2024-1", "log4j-core", "2.14.1", "/opt/app/log4j-core-2.14.1.jar", "LIBRARY"},
+			b:    [6]string{"i-1", "CVE-2024-1", "log4j-core", "2.15.0", "/opt/app/log4j-core-2.15.0.jar", "LIBRARY"},
+			same: true,
+		},
+		{
+			name: "case and separators",
+			a:    [6]string{"i-1", "cve-2024-1", "Lib", "1.0", `C:\app\lib-1.0.jar`, "library"},
+			b:    [6]string{"i-1", "CVE-2024-1", "lib", "1.0", "C:/app/lib-1.0.jar", "LIBRARY"},
+			same: true,
+		},
+	}
+	for _, tt := range tests {
+		a := FindingID(tt.a[0], tt.a[1], tt.a[2], tt.a[3], tt.a[4], tt.a[5])
+		b := FindingID(tt.b[0], tt.b[1], tt.b[2], tt.b[3], tt.b[4], tt.b[5])
+		if (a == b) != tt.same {
+			t.Errorf("%s: %s and %s, want same: %v", tt.name, a, b, tt.same)
+		}
+	}
+}
+
+func TestLegacyFindingIDIsKept(t *testing.T) {
+	vuln := wizcli.Vulnerability{Name: "CVE-2021-23337", FixedVersion: "4.17.21", Severity: "HIGH"}
+	scan := wizcli.AggregatedScanResults{Libraries: []wizcli.Library{
+		{Name: "lodash", Version: "4.17.20", Path: "/srv/a/node_modules/lodash/package.json", DetectionMethod: "LIBRARY", Vulnerabilities: []wizcli.Vulnerability{vuln}},
+		{Name: "lodash", Version: "4.17.20", Path: "/srv/b/node_modules/lodash/package.json", DetectionMethod: "LIBRARY", Vulnerabilities: []wizcli.Vulnerability{vuln}},
+	}}
+	known := []wizapi.VulnerabilityNode{
+		{ID: "i-1-CVE-2021-23337-lodash", Name: "CVE-2021-23337", DetailedName: "lodash", FixedVersion: "4.17.21", DetectionMethod: "LIBRARY", DataSourceName: "WizCLI"},
+	}
+
+	asset, _, err := CompareVulnerabilities(scan, known, "i-1", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(asset.VulnerabilityFindings) != 2 {
+		t.Fatalf("got %d findings, want 2", len(asset.VulnerabilityFindings))
+	}
+	// The legacy ID didn't include the path, so only the first path can keep it
+	if id := asset.VulnerabilityFindings[0].Id; id != known[0].ID {
+		t.Errorf("first finding has ID %s, want the legacy %s", id, known[0].ID)
+	}
+	if id := asset.VulnerabilityFindings[1].Id; id != FindingID("i-1", vuln.Name, "lodash", "4.17.20", "/srv/b/node_modules/lodash/package.json", "LIBRARY") {
+		t.Errorf("second finding has ID %s, want its deterministic ID", id)
+	}
+}
+
+func TestIsLegacyFindingID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"i-1-CVE-2021-1-openssl", true},
+		{"WIZCLI-3f2b8c1e-9d4a-4b6e-8f21-7c5d9e0a1b2c", true},
+		{FindingID("i-1", "CVE-2021-1", "openssl", "1.0", "", "OS"), false},
+		{"i-2-CVE-2021-1-openssl", false},
+	}
+	for _, tt := range tests {
+		if got := isLegacyFindingID(tt.id, "i-1", "CVE-2021-1", "openssl"); got != tt.want {
+			t.Errorf("isLegacyFindingID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
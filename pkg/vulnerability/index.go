@@ -0,0 +1,96 @@
+package vulnerability
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/jtb75/wiz-scan/pkg/wizapi"
+)
+
+// knownPathPattern extracts the library path from the description of a Wiz disk scanner finding.
+var knownPathPattern = regexp.MustCompile(`located at (.*?) and is vulnerable to`)
+
+// findingKey identifies a finding by CVE, package, path and detection method, normalized so
+// findings that only differ in case or in how the path is written still match.
+type findingKey struct {
+	CVE             string
+	Package         string
+	Path            string
+	DetectionMethod string
+}
+
+func newFindingKey(cve, pkg, filePath, detectionMethod string) findingKey {
+	return findingKey{
+		CVE:             strings.ToUpper(strings.TrimSpace(cve)),
+		Package:         strings.ToLower(strings.TrimSpace(pkg)),
+		Path:            normalizePath(filePath),
+		DetectionMethod: strings.ToUpper(strings.TrimSpace(detectionMethod)),
+	}
+}
+
+// normalizePath cleans a path with slash separators, leaving an empty path empty.
+func normalizePath(filePath string) string {
+	filePath = strings.TrimSpace(filePath)
+	if filePath == "" {
+		return ""
+	}
+	return path.Clean(strings.ReplaceAll(filePath, `\`, "/"))
+}
+
+// knownFinding is a finding already in Wiz together with the path parsed from its description.
+type knownFinding struct {
+	wizapi.VulnerabilityNode
+	Path string
+}
+
+// isWizCLI reports whether the finding was uploaded by a previous wiz-scan run.
+func (k *knownFinding) isWizCLI() bool {
+	return k.DataSourceName == "WizCLI"
+}
+
+// knownIndex indexes the findings already in Wiz so each scanned vulnerability is matched with a lookup.
+type knownIndex struct {
+	// byPath is keyed on CVE, package, path and detection method
+	byPath map[findingKey][]*knownFinding
+	// byPackage is keyed on CVE, package and detection method with an empty path
+	byPackage map[findingKey][]*knownFinding
+}
+
+func newKnownIndex(knownVulns []wizapi.VulnerabilityNode) *knownIndex {
+	index := &knownIndex{
+		byPath:    make(map[findingKey][]*knownFinding, len(knownVulns)),
+		byPackage: make(map[findingKey][]*knownFinding, len(knownVulns)),
+	}
+
+	for _, kv := range knownVulns {
+		path, err := extractPath(kv.Description)
+		if err != nil {
+			path = ""
+		}
+		finding := &knownFinding{VulnerabilityNode: kv, Path: path}
+
+		pathKey := newFindingKey(kv.Name, kv.DetailedName, path, kv.DetectionMethod)
+		index.byPath[pathKey] = append(index.byPath[pathKey], finding)
+
+		packageKey := newFindingKey(kv.Name, kv.DetailedName, "", kv.DetectionMethod)
+		index.byPackage[packageKey] = append(index.byPackage[packageKey], finding)
+	}
+
+	return index
+}
+
+// find returns the first known finding for the CVE and package that satisfies match.
+// When matchPath is set the finding must also have been reported at path.
+func (idx *knownIndex) find(cve, pkg, path, detectionMethod string, matchPath bool, match func(*knownFinding) bool) *knownFinding {
+	candidates := idx.byPackage[newFindingKey(cve, pkg, "", detectionMethod)]
+	if matchPath {
+		candidates = idx.byPath[newFindingKey(cve, pkg, path, detectionMethod)]
+	}
+	for _, candidate := range candidates {
+		if match(candidate) {
+			return candidate
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package vulnerability
+
+import (
+	"time"
+
+	"github.com/jtb75/wiz-scan/pkg/wizcli"
+)
+
+// RiskAttributes carries the scoring and exploitability data wizcli reports for a
+// vulnerability so Wiz can prioritise uploaded findings. The fields and their JSON names
+// are those of Wiz's own vulnerability findings, as read by wizapi.VulnerabilityQuery.
+// Fields wizcli leaves unset are omitted from the payload.
+type RiskAttributes struct {
+	Score              float64    `json:"score,omitempty"`
+	HasExploit         bool       `json:"hasExploit,omitempty"`
+	HasCisaKevExploit  bool       `json:"hasCisaKevExploit,omitempty"`
+	CisaKevReleaseDate *time.Time `json:"cisaKevReleaseDate,omitempty"`
+	CisaKevDueDate     *time.Time `json:"cisaKevDueDate,omitempty"`
+	EpssProbability    *float64   `json:"epssProbability,omitempty"`
+	EpssPercentile     *float64   `json:"epssPercentile,omitempty"`
+	EpssSeverity       string     `json:"epssSeverity,omitempty"`
+	PublishedDate      *time.Time `json:"publishedDate,omitempty"`
+	FixDate            *time.Time `json:"fixDate,omitempty"`
+}
+
+// riskAttributes maps the risk data of a wizcli vulnerability onto a finding.
+func riskAttributes(vuln wizcli.Vulnerability) RiskAttributes {
+	risk := RiskAttributes{
+		Score:              vuln.Score,
+		HasExploit:         vuln.HasExploit,
+		HasCisaKevExploit:  vuln.HasCisaKevExploit,
+		CisaKevReleaseDate: vuln.CisaKevReleaseDate.TimePtr(),
+		CisaKevDueDate:     vuln.CisaKevDueDate.TimePtr(),
+		EpssProbability:    vuln.EpssProbability,
+		EpssPercentile:     vuln.EpssPercentile,
+		PublishedDate:      vuln.PublishDate.TimePtr(),
+		FixDate:            vuln.FixPublishDate.TimePtr(),
+	}
+	if vuln.EpssSeverity != "" {
+		risk.EpssSeverity = normalizeAndValidateSeverity(vuln.EpssSeverity)
+	}
+	// Wiz scores a finding by its CVSS base score, so fall back on the metrics when wizcli has no score
+	if risk.Score == 0 && vuln.CvssV3Metrics != nil {
+		risk.Score = vuln.CvssV3Metrics.BaseScore
+	}
+	if risk.Score == 0 && vuln.CvssV2Metrics != nil {
+		risk.Score = vuln.CvssV2Metrics.BaseScore
+	}
+	return risk
+}
@@ -0,0 +1,223 @@
+package vulnerability
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jtb75/wiz-scan/pkg/ecosystem"
+)
+
+// TemplateData is what description and remediation templates are rendered with.
+type TemplateData struct {
+	Type           string
+	CVE            string
+	Package        string
+	Version        string
+	FixedVersion   string
+	Path           string
+	Severity       string
+	VendorSeverity string
+	Source         string
+	Ecosystem      string
+	// Upgrade is ecosystem-specific guidance on installing FixedVersion, empty without a fix.
+	Upgrade string
+}
+
+// TextTemplates are the description and remediation templates of one component type.
+type TextTemplates struct {
+	Description string
+	Remediation string
+}
+
+// TemplateConfig overrides the templates of each component type; types or fields left out keep the defaults.
+type TemplateConfig struct {
+	Library     TextTemplates
+	Application TextTemplates
+	OS          TextTemplates
+}
+
+// DefaultTemplateConfig holds the templates used when the user doesn't override them.
+var DefaultTemplateConfig = TemplateConfig{
+	Library: TextTemplates{
+		Description: "The library `{{.Package}}` version `{{.Version}}`{{with .Path}} located at `{{.}}`{{end}} is vulnerable to `{{.CVE}}`" +
+			"{{with .FixedVersion}}, which exists in versions less than `{{.}}`{{end}}.\n" +
+			"The vulnerability was found at `{{.Source}}` with vendor severity of: `{{.VendorSeverity}}`.\n" +
+			"{{if .FixedVersion}}The vulnerability can be remediated by updating the library to version `{{.FixedVersion}}` or higher." +
+			"{{else}}At this time there is not a fix for this vulnerability.{{end}}",
+		Remediation: "{{if .FixedVersion}}Update `{{.Package}}` to version `{{.FixedVersion}}` or higher.{{with .Upgrade}} {{.}}{{end}}" +
+			"{{else}}No fixed version is available yet.{{end}}",
+	},
+	Application: TextTemplates{
+		Description: "The application `{{.Package}}`{{with .Version}} version `{{.}}`{{end}}{{with .Path}} located at `{{.}}`{{end}} is vulnerable to `{{.CVE}}`" +
+			"{{with .FixedVersion}}, which exists in versions less than `{{.}}`{{end}}.\n" +
+			"{{if .Source}}The vulnerability was found at `{{.Source}}` with vendor severity of: `{{.VendorSeverity}}`." +
+			"{{else}}The vendor severity is `{{.VendorSeverity}}`.{{end}}",
+		Remediation: "{{if .FixedVersion}}Update `{{.Package}}` to version `{{.FixedVersion}}` or higher using the vendor's installer or update channel." +
+			"{{else}}No fixed version is available yet, check the vendor advisory for mitigations.{{end}}",
+	},
+	OS: TextTemplates{
+		Description: "{{if .Path}}The OS component `{{.Package}}` version `{{.Version}}` located at `{{.Path}}`" +
+			"{{else}}The OS package `{{.Package}}` version `{{.Version}}`{{end}} is vulnerable to `{{.CVE}}`" +
+			"{{with .FixedVersion}}, which exists in versions less than `{{.}}`{{end}}.\n" +
+			"The vulnerability was found at `{{.Source}}` with vendor severity of: `{{.VendorSeverity}}`.\n" +
+			"{{if .FixedVersion}}The vulnerability can be remediated by updating the package to version `{{.FixedVersion}}` or higher." +
+			"{{else}}At this time there is not a fix for this vulnerability.{{end}}",
+		Remediation: "{{if .FixedVersion}}Update `{{.Package}}` to version `{{.FixedVersion}}` or higher.{{with .Upgrade}} {{.}}{{end}}" +
+			"{{else}}No fixed package is available yet, apply the distribution's mitigations.{{end}}",
+	},
+}
+
+// Templates renders the description and remediation text of findings.
+type Templates struct {
+	byType   map[string]*compiledTemplates
+	defaults map[string]*compiledTemplates
+}
+
+type compiledTemplates struct {
+	description *template.Template
+	remediation *template.Template
+}
+
+// DefaultTemplates returns the built-in templates, compiled on first use.
+func DefaultTemplates() (*Templates, error) {
+	defaultTemplatesOnce.Do(func() {
+		defaultTemplates, defaultTemplatesErr = NewTemplates(TemplateConfig{})
+		if defaultTemplatesErr != nil {
+			defaultTemplatesErr = fmt.Errorf("invalid default templates: %w", defaultTemplatesErr)
+		}
+	})
+	return defaultTemplates, defaultTemplatesErr
+}
+
+// NewTemplates compiles config, falling back to DefaultTemplateConfig for empty templates.
+// Each template is rendered once with sample data so mistakes surface before scanning.
+func NewTemplates(config TemplateConfig) (*Templates, error) {
+	t := &Templates{
+		byType:   make(map[string]*compiledTemplates),
+		defaults: make(map[string]*compiledTemplates),
+	}
+	for _, entry := range []struct {
+		typ              string
+		custom, fallback TextTemplates
+	}{
+		{TypeLibrary, config.Library, DefaultTemplateConfig.Library},
+		{TypeApplication, config.Application, DefaultTemplateConfig.Application},
+		{TypeOS, config.OS, DefaultTemplateConfig.OS},
+	} {
+		defaults, err := compileTemplates(entry.typ, entry.fallback, entry.fallback)
+		if err != nil {
+			return nil, err
+		}
+		custom, err := compileTemplates(entry.typ, entry.custom, entry.fallback)
+		if err != nil {
+			return nil, err
+		}
+		t.defaults[entry.typ] = defaults
+		t.byType[entry.typ] = custom
+	}
+	return t, nil
+}
+
+func compileTemplates(typ string, custom, fallback TextTemplates) (*compiledTemplates, error) {
+	if custom.Description == "" {
+		custom.Description = fallback.Description
+	}
+	if custom.Remediation == "" {
+		custom.Remediation = fallback.Remediation
+	}
+
+	sample := TemplateData{Type: typ, CVE: "CVE-2021-44228", Package: "sample", Version: "1.0.0", FixedVersion: "1.0.1",
+		Path: "/opt/sample", Severity: "High", VendorSeverity: "HIGH", Source: "https://nvd.nist.gov", Upgrade: "Upgrade it."}
+	compiled := &compiledTemplates{}
+	for _, tmpl := range []struct {
+		name   string
+		text   string
+		target **template.Template
+	}{
+		{"description", custom.Description, &compiled.description},
+		{"remediation", custom.Remediation, &compiled.remediation},
+	} {
+		parsed, err := template.New(strings.ToLower(typ) + " " + tmpl.name).Option("missingkey=error").Parse(tmpl.text)
+		if err != nil {
+			return nil, err
+		}
+		if err := parsed.Execute(new(bytes.Buffer), sample); err != nil {
+			return nil, err
+		}
+		*tmpl.target = parsed
+	}
+	return compiled, nil
+}
+
+// render returns the description and remediation of a finding. A template that fails on
+// real data is logged and replaced by the default so the finding is still uploaded.
+func (t *Templates) render(data TemplateData) (description, remediation string) {
+	if t == nil {
+		var err error
+		if t, err = DefaultTemplates(); err != nil {
+			logrus.Errorf("Failed to render text for %s: %v", data.CVE, err)
+			return "", ""
+		}
+	}
+	custom, defaults := t.byType[data.Type], t.defaults[data.Type]
+	return execute(custom.description, defaults.description, data), execute(custom.remediation, defaults.remediation, data)
+}
+
+func execute(tmpl, fallback *template.Template, data TemplateData) string {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		logrus.Warnf("Failed to render %s template for %s: %v", tmpl.Name(), data.CVE, err)
+		b.Reset()
+		if err := fallback.Execute(&b, data); err != nil {
+			return ""
+		}
+	}
+	return b.String()
+}
+
+var (
+	defaultTemplatesOnce sync.Once
+	defaultTemplates     *Templates
+	defaultTemplatesErr  error
+)
+
+// upgradeGuidance suggests how to install the fixed version with the ecosystem's tooling.
+func upgradeGuidance(eco ecosystem.Ecosystem, name, fixedVersion string) string {
+	if fixedVersion == "" {
+		return ""
+	}
+	switch eco {
+	case ecosystem.Maven:
+		return fmt.Sprintf("Set the `%s` dependency to `%s` in pom.xml or build.gradle and rebuild the application.", name, fixedVersion)
+	case ecosystem.Npm:
+		return fmt.Sprintf("Run `npm install %s@%s` and redeploy the application.", name, fixedVersion)
+	case ecosystem.PyPI:
+		return fmt.Sprintf("Run `pip install --upgrade '%s>=%s'`.", name, fixedVersion)
+	case ecosystem.NuGet:
+		return fmt.Sprintf("Run `dotnet add package %s --version %s` and rebuild the application.", name, fixedVersion)
+	case ecosystem.Go:
+		if !strings.HasPrefix(fixedVersion, "v") {
+			fixedVersion = "v" + fixedVersion
+		}
+		return fmt.Sprintf("Run `go get %s@%s` and rebuild the binary.", name, fixedVersion)
+	case ecosystem.RubyGems:
+		return fmt.Sprintf("Set `gem '%s', '>= %s'` in the Gemfile and run `bundle update %s`.", name, fixedVersion, name)
+	case ecosystem.Cargo:
+		return fmt.Sprintf("Run `cargo update -p %s --precise %s` and rebuild the binary.", name, fixedVersion)
+	case ecosystem.Composer:
+		return fmt.Sprintf("Run `composer require %s:^%s`.", name, fixedVersion)
+	case ecosystem.Debian:
+		return fmt.Sprintf("Run `apt-get update && apt-get install --only-upgrade %s`.", name)
+	case ecosystem.RPM:
+		return fmt.Sprintf("Run `dnf upgrade %s` (or `yum update %s`).", name, name)
+	case ecosystem.Alpine:
+		return fmt.Sprintf("Run `apk upgrade %s`.", name)
+	default:
+		return ""
+	}
+}
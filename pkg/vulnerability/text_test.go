@@ -0,0 +1,35 @@
+package vulnerability
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultTemplatesCompile(t *testing.T) {
+	templates, err := DefaultTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	description, remediation := templates.render(TemplateData{Type: TypeLibrary, CVE: "CVE-2021-23337", Package: "lodash", Version: "4.17.20", FixedVersion: "4.17.21"})
+	if !strings.Contains(description, "`lodash` version `4.17.20`") || !strings.Contains(remediation, "`4.17.21`") {
+		t.Errorf("unexpected text %q, %q", description, remediation)
+	}
+}
+
+func TestNewTemplatesKeepsDefaultsForMissingTemplates(t *testing.T) {
+	templates, err := NewTemplates(TemplateConfig{OS: TextTemplates{Remediation: "Patch {{.Package}}."}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	description, remediation := templates.render(TemplateData{Type: TypeOS, CVE: "CVE-2023-0286", Package: "openssl", Version: "1.1.1n"})
+	if remediation != "Patch openssl." {
+		t.Errorf("remediation is %q", remediation)
+	}
+	if !strings.Contains(description, "The OS package `openssl`") {
+		t.Errorf("description is %q", description)
+	}
+
+	if _, err := NewTemplates(TemplateConfig{Library: TextTemplates{Description: "{{.Unknown}}"}}); err == nil {
+		t.Error("a template using an unknown field was accepted")
+	}
+}
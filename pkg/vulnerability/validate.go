@@ -0,0 +1,98 @@
+package vulnerability
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// enrichmentSchema is the JSON schema of the payload PublishVulns uploads. It describes the
+// fields wiz-scan sends as Wiz has accepted them, not Wiz's published schema, so it is kept
+// no stricter than uploads that are known to be ingested.
+//
+//go:embed enrichment_schema.json
+var enrichmentSchema string
+
+// ValidationError lists every problem found in a payload.
+type ValidationError struct {
+	Violations []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("payload has %d violations:\n  %s", len(e.Violations), strings.Join(e.Violations, "\n  "))
+}
+
+// DropDuplicateFindings removes findings whose ID was already used by an earlier finding of
+// the asset, logging each, and returns how many were removed. The same vulnerability can be
+// reported twice by the scanner; uploading it once is enough.
+func DropDuplicateFindings(asset *Asset) int {
+	seen := make(map[string]bool, len(asset.VulnerabilityFindings))
+	kept := asset.VulnerabilityFindings[:0]
+	for _, finding := range asset.VulnerabilityFindings {
+		if seen[finding.Id] {
+			logrus.Warnf("Dropping duplicate finding %s: %s in %s %s", finding.Id, finding.Name, finding.DetailedName, finding.Path)
+			continue
+		}
+		seen[finding.Id] = true
+		kept = append(kept, finding)
+	}
+	dropped := len(asset.VulnerabilityFindings) - len(kept)
+	asset.VulnerabilityFindings = kept
+	return dropped
+}
+
+// Validate checks data against the enrichment schema and the rules the schema can't express,
+// so problems are reported together before the upload instead of as an ingestion failure.
+func Validate(data IntegrationData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(enrichmentSchema), gojsonschema.NewBytesLoader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to validate payload: %w", err)
+	}
+
+	var violations []string
+	for _, e := range result.Errors() {
+		violations = append(violations, fmt.Sprintf("%s: %s", e.Field(), e.Description()))
+	}
+	violations = append(violations, semanticViolations(data, time.Now())...)
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func semanticViolations(data IntegrationData, now time.Time) []string {
+	var violations []string
+	for i, ds := range data.DataSources {
+		dsField := fmt.Sprintf("dataSources.%d", i)
+		if ds.AnalysisDate.IsZero() {
+			violations = append(violations, dsField+".analysisDate: is not set")
+		} else if ds.AnalysisDate.After(now.Add(time.Hour)) {
+			violations = append(violations, fmt.Sprintf("%s.analysisDate: %s is in the future", dsField, ds.AnalysisDate.Format(time.RFC3339)))
+		}
+
+		for j, asset := range ds.Assets {
+			assetField := fmt.Sprintf("%s.assets.%d", dsField, j)
+			for k, finding := range asset.VulnerabilityFindings {
+				field := fmt.Sprintf("%s.vulnerabilityFindings.%d", assetField, k)
+				if finding.FixedVersion != "" && finding.Version == "" {
+					violations = append(violations, field+".version: is required when fixedVersion is set")
+				}
+				if release, due := finding.CisaKevReleaseDate, finding.CisaKevDueDate; release != nil && due != nil && due.Before(*release) {
+					violations = append(violations, field+".cisaKevDueDate: is before cisaKevReleaseDate")
+				}
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,52 @@
+package vulnerability
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeAndValidateDataSource(t *testing.T) {
+	tests := map[string]string{
+		"LIBRARY":          "Library",
+		"SOFTWARE_LIBRARY": "Software_library",
+		"FILE_PATH":        "File_path",
+		"OS":               "OS",
+		"":                 "",
+	}
+	for input, want := range tests {
+		if got := normalizeAndValidateDataSource(input); got != want {
+			t.Errorf("normalizeAndValidateDataSource(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestDropDuplicateFindings(t *testing.T) {
+	asset := Asset{VulnerabilityFindings: []VulnerabilityFinding{
+		{Id: "WIZCLI-1", Name: "CVE-1"},
+		{Id: "WIZCLI-2", Name: "CVE-2"},
+		{Id: "WIZCLI-1", Name: "CVE-1"},
+	}}
+	if dropped := DropDuplicateFindings(&asset); dropped != 1 {
+		t.Errorf("dropped %d findings, want 1", dropped)
+	}
+	if len(asset.VulnerabilityFindings) != 2 || asset.VulnerabilityFindings[1].Id != "WIZCLI-2" {
+		t.Errorf("kept %+v", asset.VulnerabilityFindings)
+	}
+
+	data := IntegrationData{
+		IntegrationId: "55c176cc-d155-43a2-98ed-aa56873a1ca1",
+		DataSources: []DataSource{{
+			Id:           "wiz-scan",
+			AnalysisDate: time.Now().Add(-time.Minute),
+			Assets: []Asset{{
+				AssetIdentifier: AssetIdentifier{CloudPlatform: "AWS", ProviderId: "i-0123456789"},
+				VulnerabilityFindings: []VulnerabilityFinding{
+					{Id: "WIZCLI-1", Name: "CVE-1", DetailedName: "lodash", ExternalDetectionSource: "Software_library", Severity: "High"},
+				},
+			}},
+		}},
+	}
+	if err := Validate(data); err != nil {
+		t.Errorf("a finding of a software library is rejected: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package vulnerability
+
+// Action is the decision CompareVulnerabilities made for a scanned vulnerability.
+type Action string
+
+const (
+	// ActionAdd uploads a vulnerability Wiz doesn't know about yet
+	ActionAdd Action = "Add"
+	// ActionKeep uploads a vulnerability wiz-scan reported before so it stays current
+	ActionKeep Action = "Keep"
+	// ActionIgnore skips a vulnerability Wiz already detected on its own
+	ActionIgnore Action = "Ignore"
+	// ActionDrop skips a vulnerability a policy rule excluded from the upload
+	ActionDrop Action = "Drop"
+	// ActionSuppress skips a vulnerability covered by a local suppression or VEX statement
+	ActionSuppress Action = "Suppress"
+)
+
+// Component types a Verdict can refer to.
+const (
+	TypeLibrary     = "Library"
+	TypeApplication = "Application"
+	TypeOS          = "OS"
+)
+
+// Verdict records why a scanned vulnerability was or wasn't uploaded and the Wiz finding it matched.
+type Verdict struct {
+	Action       Action `json:"action"`
+	Reason       string `json:"reason"`
+	Type         string `json:"type"`
+	CVE          string `json:"cve"`
+	Package      string `json:"package"`
+	Version      string `json:"version"`
+	FixedVersion string `json:"fixedVersion"`
+	Path         string `json:"path"`
+	Severity     string `json:"severity"`
+	FindingID    string `json:"findingId"`
+	WizFindingID string `json:"wizFindingId,omitempty"`
+
+	// Risk is the risk data of the vulnerability, for policy rules
+	Risk RiskAttributes `json:"-"`
+	// AlreadyFixed is set when the installed version is not below the fixed version
+	AlreadyFixed bool `json:"-"`
+}
+
+// OnHost reports whether the vulnerability is present on the host, which is every
+// verdict but an Ignore because the installed version is already fixed.
+func (v *Verdict) OnHost() bool {
+	return !v.AlreadyFixed
+}
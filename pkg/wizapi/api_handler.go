@@ -6,11 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 // WizAPI represents the client for interacting with the Wiz API.
@@ -100,14 +101,14 @@ func (w *WizAPI) QueryWithRetry(query string, variables map[string]interface{})
 	// Convert the data to JSON
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		log.Printf("Error marshaling query data: %s\n", err)
+		logrus.Errorf("Error marshaling query data: %v", err)
 		return nil, err
 	}
 
 	// Create the HTTP request
 	request, err := http.NewRequest("POST", w.ClientQueryURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		log.Printf("Error creating request: %s\n", err)
+		logrus.Errorf("Error creating request: %v", err)
 		return nil, err
 	}
 
@@ -123,7 +124,7 @@ func (w *WizAPI) QueryWithRetry(query string, variables map[string]interface{})
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		response, err = w.Session.Do(request)
 		if err != nil {
-			log.Printf("Error querying Wiz API: %s\n", err)
+			logrus.Warnf("Error querying Wiz API: %v", err)
 			time.Sleep(retryDelay) // Wait before retrying
 			continue               // Proceed to the next attempt
 		}
@@ -138,7 +139,7 @@ func (w *WizAPI) QueryWithRetry(query string, variables map[string]interface{})
 			response.Body.Close()
 		}
 
-		log.Printf("Retrying due to status code: %d, attempt: %d\n", response.StatusCode, attempt+1)
+		logrus.Warnf("Retrying due to status code: %d, attempt: %d", response.StatusCode, attempt+1)
 		time.Sleep(retryDelay) // Wait before retrying
 	}
 
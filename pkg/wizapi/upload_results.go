@@ -111,6 +111,42 @@ type IngestionStatsDetails struct {
 	Handled  int `json:"handled"`
 }
 
+// PublishResult is the ingestion outcome of one or more uploads, as reported by their System Activities.
+type PublishResult struct {
+	Uploads               int
+	Status                string
+	StatusInfo            string
+	DataSources           IngestionStatsDetails
+	Findings              IngestionStatsDetails
+	Events                IngestionStatsDetails
+	Tags                  IngestionStatsDetails
+	UnresolvedAssetsCount int
+	UnresolvedAssetIDs    []string
+}
+
+// Add folds the result of another upload into r. The status stays "SUCCESS" only while every upload succeeded.
+func (r *PublishResult) Add(other *PublishResult) {
+	if other == nil {
+		return
+	}
+	if r.Status == "" || (r.Status == "SUCCESS" && other.Status != "SUCCESS") {
+		r.Status = other.Status
+		r.StatusInfo = other.StatusInfo
+	}
+	r.Uploads += other.Uploads
+	r.DataSources.add(other.DataSources)
+	r.Findings.add(other.Findings)
+	r.Events.add(other.Events)
+	r.Tags.add(other.Tags)
+	r.UnresolvedAssetsCount += other.UnresolvedAssetsCount
+	r.UnresolvedAssetIDs = append(r.UnresolvedAssetIDs, other.UnresolvedAssetIDs...)
+}
+
+func (d *IngestionStatsDetails) add(other IngestionStatsDetails) {
+	d.Incoming += other.Incoming
+	d.Handled += other.Handled
+}
+
 // GraphQLRequest represents a request to a GraphQL API.
 type GraphQLRequest struct {
 	Query     string                 `json:"query"`     // The GraphQL query string
@@ -173,20 +209,21 @@ func (w *WizAPI) querySystemActivity(systemActivityID string) (*SystemActivityRe
 	return &systemActivityResponse, nil
 }
 
-// PublishVulns handles the publication of vulnerability findings by uploading them to an S3 bucket.
-func (w *WizAPI) PublishVulns(tempFilePath string) error {
+// PublishVulns handles the publication of vulnerability findings by uploading them to an S3 bucket
+// and returns the ingestion result of the upload.
+func (w *WizAPI) PublishVulns(tempFilePath string) (*PublishResult, error) {
 	uploadResponse, err := w.requestSecurityScanUpload(tempFilePath)
 	if err != nil {
-		return fmt.Errorf("failed to request upload URL: %w", err)
+		return nil, fmt.Errorf("failed to request upload URL: %w", err)
 	}
 
 	uploadURL := uploadResponse.Data.RequestSecurityScanUpload.Upload.URL
 	if uploadURL == "" {
-		return fmt.Errorf("received empty upload URL")
+		return nil, fmt.Errorf("received empty upload URL")
 	}
 
 	if err := utilities.S3Upload(uploadURL, tempFilePath); err != nil {
-		return fmt.Errorf("failed to upload file to S3: %w", err)
+		return nil, fmt.Errorf("failed to upload file to S3: %w", err)
 	}
 
 	const maxRetries = 5
@@ -214,11 +251,22 @@ func (w *WizAPI) PublishVulns(tempFilePath string) error {
 		break
 	}
 
-	if err == nil {
-		logrus.Infof("System Activity Status: %s", systemActivityResponse.Data.SystemActivity.Status)
-	} else {
+	if err != nil {
 		logrus.Error("Failed to query system activity after retries.")
+		return nil, err
 	}
 
-	return err // Return the final state of 'err', whether it's nil or contains an error
+	activity := systemActivityResponse.Data.SystemActivity
+	logrus.Infof("System Activity Status: %s", activity.Status)
+	return &PublishResult{
+		Uploads:               1,
+		Status:                activity.Status,
+		StatusInfo:            activity.StatusInfo,
+		DataSources:           activity.Result.DataSources,
+		Findings:              activity.Result.Findings,
+		Events:                activity.Result.Events,
+		Tags:                  activity.Result.Tags,
+		UnresolvedAssetsCount: activity.Result.UnresolvedAssets.Count,
+		UnresolvedAssetIDs:    activity.Result.UnresolvedAssets.IDs,
+	}, nil
 }
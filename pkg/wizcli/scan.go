@@ -1,19 +1,30 @@
 package wizcli
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
-	"regexp"
-	"runtime"
+	"strings"
 	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Detection methods reported by wizcli, also assigned to results from other sources that don't report one.
+const (
+	DetectionMethodLibrary = "LIBRARY"
+	DetectionMethodPackage = "PACKAGE"
 )
 
 type AggregatedScanResults struct {
+	OsPackages   []OsPackage    `json:"osPackages"`
 	Libraries    []Library      `json:"libraries"`
 	Applications []Applications `json:"applications"`
+	Cpes         []Cpe          `json:"cpes"`
 }
 
 type ScanOutput struct {
@@ -30,10 +41,27 @@ type ScanOriginResource struct {
 }
 
 type Result struct {
-	OsPackages   interface{}    `json:"osPackages"`
+	OsPackages   []OsPackage    `json:"osPackages"`
 	Libraries    []Library      `json:"libraries"`
 	Applications []Applications `json:"applications"`
-	Cpes         interface{}    `json:"cpes"`
+	Cpes         []Cpe          `json:"cpes"`
+}
+
+// OsPackage is a package installed through the operating system package manager (dpkg, rpm, apk).
+type OsPackage struct {
+	Name            string          `json:"name"`
+	Version         string          `json:"version"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+	DetectionMethod string          `json:"detectionMethod"`
+}
+
+// Cpe is a component identified by its CPE rather than a package manager, such as the kernel.
+type Cpe struct {
+	Name            string          `json:"name"`
+	Version         string          `json:"version"`
+	Path            string          `json:"path"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+	DetectionMethod string          `json:"detectionMethod"`
 }
 
 type Library struct {
@@ -58,87 +86,245 @@ type VulnerabilityDetail struct {
 }
 
 type Vulnerability struct {
-	Name                      string      `json:"name"`
-	Severity                  string      `json:"severity"`
-	FixedVersion              string      `json:"fixedVersion"`
-	Source                    string      `json:"source"`
-	Description               interface{} `json:"description"`
-	Score                     float64     `json:"score"`
-	ExploitabilityScore       float64     `json:"exploitabilityScore"`
-	CvssV3Metrics             interface{} `json:"cvssV3Metrics"`
-	CvssV2Metrics             interface{} `json:"cvssV2Metrics"`
-	HasExploit                bool        `json:"hasExploit"`
-	HasCisaKevExploit         bool        `json:"hasCisaKevExploit"`
-	CisaKevReleaseDate        interface{} `json:"cisaKevReleaseDate"`
-	CisaKevDueDate            interface{} `json:"cisaKevDueDate"`
-	EpssProbability           interface{} `json:"epssProbability"`
-	EpssPercentile            interface{} `json:"epssPercentile"`
-	EpssSeverity              interface{} `json:"epssSeverity"`
-	PublishDate               interface{} `json:"publishDate"`
-	FixPublishDate            interface{} `json:"fixPublishDate"`
-	GracePeriodEnd            interface{} `json:"gracePeriodEnd"`
-	GracePeriodRemainingHours interface{} `json:"gracePeriodRemainingHours"`
+	Name                      string       `json:"name"`
+	Severity                  string       `json:"severity"`
+	FixedVersion              string       `json:"fixedVersion"`
+	Source                    string       `json:"source"`
+	Description               interface{}  `json:"description"`
+	Score                     float64      `json:"score"`
+	ExploitabilityScore       float64      `json:"exploitabilityScore"`
+	CvssV3Metrics             *CvssMetrics `json:"cvssV3Metrics"`
+	CvssV2Metrics             *CvssMetrics `json:"cvssV2Metrics"`
+	HasExploit                bool         `json:"hasExploit"`
+	HasCisaKevExploit         bool         `json:"hasCisaKevExploit"`
+	CisaKevReleaseDate        *Date        `json:"cisaKevReleaseDate"`
+	CisaKevDueDate            *Date        `json:"cisaKevDueDate"`
+	EpssProbability           *float64     `json:"epssProbability"`
+	EpssPercentile            *float64     `json:"epssPercentile"`
+	EpssSeverity              string       `json:"epssSeverity"`
+	PublishDate               *Date        `json:"publishDate"`
+	FixPublishDate            *Date        `json:"fixPublishDate"`
+	GracePeriodEnd            *Date        `json:"gracePeriodEnd"`
+	GracePeriodRemainingHours *float64     `json:"gracePeriodRemainingHours"`
+}
+
+// CvssMetrics holds the CVSS base metrics wizcli reports for a vulnerability.
+type CvssMetrics struct {
+	BaseScore             float64 `json:"baseScore"`
+	ExploitabilityScore   float64 `json:"exploitabilityScore"`
+	ImpactScore           float64 `json:"impactScore"`
+	AttackVector          string  `json:"attackVector"`
+	AttackComplexity      string  `json:"attackComplexity"`
+	PrivilegesRequired    string  `json:"privilegesRequired"`
+	UserInteraction       string  `json:"userInteraction"`
+	Scope                 string  `json:"scope"`
+	ConfidentialityImpact string  `json:"confidentialityImpact"`
+	IntegrityImpact       string  `json:"integrityImpact"`
+	AvailabilityImpact    string  `json:"availabilityImpact"`
+}
+
+// Date is a timestamp in wizcli output, which may be RFC 3339, a date and time without
+// a zone, taken as UTC, or a plain date.
+type Date struct {
+	time.Time
+}
+
+// dateLayouts are the formats Date accepts, tried in order.
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// UnmarshalJSON parses a date in any of the dateLayouts. A date in another format is logged
+// and left unset rather than failing the whole scan result.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		logrus.Debugf("Ignoring date %s in wizcli output: %v", data, err)
+		return nil
+	}
+	if s == "" {
+		return nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			d.Time = t
+			return nil
+		}
+	}
+	logrus.Warnf("Ignoring date %q in wizcli output, it has an unknown format", s)
+	return nil
+}
+
+// TimePtr returns the date as a *time.Time, nil when unset.
+func (d *Date) TimePtr() *time.Time {
+	if d == nil || d.IsZero() {
+		return nil
+	}
+	t := d.Time
+	return &t
+}
+
+// Outcome classifies how a wizcli invocation ended based on its exit code.
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota
+	OutcomePolicyFailure
+	OutcomeAuthFailure
+	OutcomeScanError
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomePolicyFailure:
+		return "policy failure"
+	case OutcomeAuthFailure:
+		return "authentication failure"
+	default:
+		return "scan error"
+	}
+}
+
+// OutcomeFromExitCode maps a wizcli exit code to an Outcome.
+// wizcli exits with 0 on success, 3 on authentication errors and 4 when the
+// scan completed but violated a policy; everything else is a scan error.
+func OutcomeFromExitCode(code int) Outcome {
+	switch code {
+	case 0:
+		return OutcomeSuccess
+	case 3:
+		return OutcomeAuthFailure
+	case 4:
+		return OutcomePolicyFailure
+	default:
+		return OutcomeScanError
+	}
+}
+
+// ErrSchemaMismatch is returned when wizcli produced JSON that does not match ScanOutput.
+var ErrSchemaMismatch = errors.New("wizcli output does not match the expected schema")
+
+// ScanError describes a failed wizcli run, including the captured stderr.
+type ScanError struct {
+	Path     string
+	Outcome  Outcome
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *ScanError) Error() string {
+	msg := fmt.Sprintf("wizcli scan of %s failed (%s, exit code %d): %v", e.Path, e.Outcome, e.ExitCode, e.Err)
+	if e.Stderr != "" {
+		msg += " - Stderr: " + e.Stderr
+	}
+	return msg
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
 }
 
 // ScanDirectory uses wizcli to scan the specified directory for vulnerabilities and parses the JSON output.
-func ScanDirectory(wizcliPath, directoryPath string) (*ScanOutput, error) {
+// A policy failure is not treated as an error since the scan results are still complete.
+func ScanDirectory(wizcliPath, directoryPath string) (*ScanOutput, Outcome, error) {
 
 	// Get hostname to be used as scan name
 	hostname, err := os.Hostname()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get hostname - Output %s", err)
+		return nil, OutcomeScanError, fmt.Errorf("failed to get hostname - Output %s", err)
 	}
 
 	scanName := hostname + "-" + directoryPath
 
-	// Construct the command to scan the directory, handling Windows and Unix-like systems appropriately.
-	cmdStr := fmt.Sprintf("%s dir scan --path %s -f json --name %s", wizcliPath, directoryPath, scanName)
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", cmdStr)
-	} else {
-		cmd = exec.Command("sh", "-c", cmdStr)
+	// Run wizcli directly rather than through a shell so paths with spaces survive intact,
+	// and keep stdout (the JSON result) apart from stderr (progress and log output).
+	cmd := exec.Command(wizcliPath, "dir", "scan", "--path", directoryPath, "-f", "json", "--name", scanName)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	logStderr(directoryPath, stderr.String())
+
+	outcome := OutcomeSuccess
+	exitCode := 0
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(runErr, &exitErr) {
+			return nil, OutcomeScanError, &ScanError{Path: directoryPath, Outcome: OutcomeScanError, ExitCode: -1, Stderr: tail(stderr.String()), Err: runErr}
+		}
+		exitCode = exitErr.ExitCode()
+		outcome = OutcomeFromExitCode(exitCode)
+		if outcome != OutcomePolicyFailure {
+			return nil, outcome, &ScanError{Path: directoryPath, Outcome: outcome, ExitCode: exitCode, Stderr: tail(stderr.String()), Err: runErr}
+		}
+		logrus.Debugf("wizcli reported policy violations for %s", directoryPath)
 	}
 
-	// Execute the command and capture its combined output.
-	output, err := cmd.CombinedOutput()
+	// Parse the JSON document written to stdout into the ScanOutput struct.
+	scanResult, err := parseScanOutput(stdout.Bytes())
 	if err != nil {
-		// Handle the case where the command execution results in an error not related to parsing.
-		errMsg := err.Error()
-		if errMsg != "exit status 4" {
-			return nil, fmt.Errorf("failed to scan directory %s: %v - Output: %s", directoryPath, err, string(output))
+		return nil, OutcomeScanError, &ScanError{Path: directoryPath, Outcome: OutcomeScanError, ExitCode: exitCode, Stderr: tail(stderr.String()), Err: err}
+	}
+
+	return scanResult, outcome, nil
+}
+
+// parseScanOutput decodes the JSON document wizcli wrote to stdout.
+// Anything printed before the document (e.g. banners) is skipped by starting at the first line opening an object.
+func parseScanOutput(output []byte) (*ScanOutput, error) {
+	var document []byte
+	lines := bytes.SplitAfter(output, []byte("\n"))
+	for i, line := range lines {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("{")) {
+			document = bytes.Join(lines[i:], nil)
+			break
 		}
 	}
+	if document == nil {
+		return nil, fmt.Errorf("no JSON document found in wizcli output")
+	}
 
-	// Attempt to extract the JSON portion from the mixed output.
-	jsonOutput, err := extractJSON(string(output))
-	if err != nil {
-		// Handle the case where JSON extraction fails.
-		return nil, fmt.Errorf("failed to extract JSON from scan output: %v", err)
+	// Check the top level layout first so a changed schema produces a clear error
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(bytes.NewReader(document)).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode wizcli JSON output: %v", err)
+	}
+	if _, ok := raw["result"]; !ok {
+		return nil, fmt.Errorf("%w: missing \"result\" object", ErrSchemaMismatch)
 	}
 
-	// Parse the extracted JSON output into the ScanOutput struct.
 	var scanResult ScanOutput
-	if err := json.Unmarshal([]byte(jsonOutput), &scanResult); err != nil {
-		// Handle JSON parsing errors.
+	if err := json.NewDecoder(bytes.NewReader(document)).Decode(&scanResult); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("%w: field %q is %s, expected %s", ErrSchemaMismatch, typeErr.Field, typeErr.Value, typeErr.Type)
+		}
 		return nil, fmt.Errorf("failed to parse scan output: %v", err)
 	}
 
-	// Log completion and return the parsed scan results.
 	return &scanResult, nil
 }
 
-// extractJSON tries to find and extract the JSON substring from the provided text.
-func extractJSON(output string) (string, error) {
-	// Use a regular expression to identify the JSON part of the output.
-	// This regex assumes the JSON object starts with '{' and ends with '}'.
-	re := regexp.MustCompile(`\{.*\}`)
-	matches := re.FindStringSubmatch(output)
-	if len(matches) == 0 {
-		return "", fmt.Errorf("no JSON output found in command output")
+// logStderr forwards wizcli's stderr to our logs one line at a time.
+func logStderr(directoryPath, stderr string) {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			logrus.WithField("path", directoryPath).Debugf("wizcli: %s", line)
+		}
 	}
+}
 
-	return matches[0], nil
+// tail returns the last few lines of the output so errors stay readable.
+func tail(output string) string {
+	const maxLines = 20
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	return strings.Join(lines, "\n")
 }
 
 // LoadScanResults loads scan results from a JSON file into AggregatedScanResults struct
@@ -0,0 +1,43 @@
+package wizcli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDateUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want time.Time
+	}{
+		{`"2023-01-02T03:04:05Z"`, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{`"2023-01-02T03:04:05.5+02:00"`, time.Date(2023, 1, 2, 1, 4, 5, 500000000, time.UTC)},
+		{`"2023-01-02T03:04:05"`, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{`"2023-01-02 03:04:05"`, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{`"2023-01-02"`, time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{`""`, time.Time{}},
+		{`null`, time.Time{}},
+		{`"02/01/2023"`, time.Time{}},
+		{`20230102`, time.Time{}},
+	}
+	for _, tt := range tests {
+		var d Date
+		if err := json.Unmarshal([]byte(tt.json), &d); err != nil {
+			t.Errorf("unmarshal %s: %v", tt.json, err)
+			continue
+		}
+		if !d.Time.Equal(tt.want) {
+			t.Errorf("unmarshal %s = %s, want %s", tt.json, d.Time, tt.want)
+		}
+	}
+
+	// A date in an unknown format leaves the rest of the vulnerability intact
+	var vuln Vulnerability
+	if err := json.Unmarshal([]byte(`{"name":"CVE-2023-1","publishDate":"Jan 2 2023","score":7.5}`), &vuln); err != nil {
+		t.Fatal(err)
+	}
+	if vuln.Name != "CVE-2023-1" || vuln.Score != 7.5 || vuln.PublishDate.TimePtr() != nil {
+		t.Errorf("got %+v", vuln)
+	}
+}
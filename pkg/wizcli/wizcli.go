@@ -8,6 +8,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+
+	"github.com/sirupsen/logrus"
 )
 
 // WizCliURLs holds the download URLs for wizcli binaries for different platforms and architectures.
@@ -36,6 +38,9 @@ func DownloadFile(filepath string, url string) error {
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download of %s failed with status %s", url, resp.Status)
+	}
 
 	out, err := os.Create(filepath)
 	if err != nil {
@@ -117,7 +122,7 @@ func InitializeAndAuthenticate(clientID, clientSecret string) (cleanupFunc func(
 
 	cleanupFunc = func() {
 		if err := CleanupEnvironment(wizCliPath); err != nil {
-			fmt.Printf("Warning: Failed to clean up environment: %v", err)
+			logrus.Warnf("Failed to clean up environment: %v", err)
 		}
 	}
 
@@ -134,8 +139,8 @@ func InitializeAndAuthenticate(clientID, clientSecret string) (cleanupFunc func(
 		cleanupFunc()
 		return nil, "", err
 	}
-	if authMessage == "" {
-		fmt.Println(authMessage)
+	if authMessage != "" {
+		logrus.Debug(authMessage)
 	}
 
 	return cleanupFunc, wizCliPath, nil // Now also returning the path to wizcli